@@ -0,0 +1,68 @@
+package bot
+
+import (
+	"sync"
+	"time"
+)
+
+// maxCacheEntries limits number of cached responses
+const maxCacheEntries = 1000
+
+type cacheEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// responseCache stores API responses with TTL. Expired entries are kept
+// to be served when quota budget runs low until cache is full
+type responseCache struct {
+	sync.RWMutex
+	ttl   time.Duration
+	items map[string]cacheEntry
+}
+
+func newResponseCache(ttl time.Duration) *responseCache {
+	return &responseCache{
+		ttl:   ttl,
+		items: make(map[string]cacheEntry),
+	}
+}
+
+// get returns cached value and true as second value if it's not expired yet
+func (c *responseCache) get(key string) (interface{}, bool, bool) {
+	c.RLock()
+	defer c.RUnlock()
+	entry, ok := c.items[key]
+	if !ok {
+		return nil, false, false
+	}
+	return entry.value, time.Now().Before(entry.expires), true
+}
+
+func (c *responseCache) set(key string, value interface{}) {
+	c.Lock()
+	defer c.Unlock()
+	now := time.Now()
+	if _, ok := c.items[key]; !ok && len(c.items) >= maxCacheEntries {
+		c.evict(now)
+	}
+	c.items[key] = cacheEntry{value: value, expires: now.Add(c.ttl)}
+}
+
+// evict drops expired entries, the oldest entry is dropped if none expired
+func (c *responseCache) evict(now time.Time) {
+	var oldest string
+	var oldestExpires time.Time
+	for key, entry := range c.items {
+		if now.After(entry.expires) {
+			delete(c.items, key)
+			continue
+		}
+		if oldest == "" || entry.expires.Before(oldestExpires) {
+			oldest, oldestExpires = key, entry.expires
+		}
+	}
+	if len(c.items) >= maxCacheEntries {
+		delete(c.items, oldest)
+	}
+}
@@ -0,0 +1,148 @@
+package bot
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultFeedURL is url of YouTube channel uploads feed
+const DefaultFeedURL = "https://www.youtube.com/feeds/videos.xml"
+
+// FeedEntry is a video from channel uploads feed
+type FeedEntry struct {
+	VideoID   string
+	ChannelID string
+	Title     string
+	Published time.Time
+	Updated   time.Time
+}
+
+type atomFeed struct {
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	VideoID   string `xml:"http://www.youtube.com/xml/schemas/2015 videoId"`
+	ChannelID string `xml:"http://www.youtube.com/xml/schemas/2015 channelId"`
+	Title     string `xml:"title"`
+	Published string `xml:"published"`
+	Updated   string `xml:"updated"`
+}
+
+type feedState struct {
+	etag         string
+	lastModified string
+	entries      []FeedEntry
+}
+
+// FeedClient polls channel uploads feeds, which don't spend API quota.
+// Feeds are requested conditionally with ETag and Last-Modified
+type FeedClient struct {
+	sync.Mutex
+	baseURL string
+	client  *http.Client
+	feeds   map[string]feedState
+}
+
+// NewFeedClient creates feed client for baseURL, DefaultFeedURL is used if it's empty
+func NewFeedClient(baseURL string) *FeedClient {
+	if baseURL == "" {
+		baseURL = DefaultFeedURL
+	}
+	return &FeedClient{
+		baseURL: baseURL,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		feeds:   make(map[string]feedState),
+	}
+}
+
+// Channel returns recent uploads of channel. Channel may be channel ID or username
+func (fc *FeedClient) Channel(channel string) ([]FeedEntry, error) {
+	channel = strings.TrimSpace(channel)
+	u, err := url.Parse(fc.baseURL)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	if isChannelID(channel) {
+		q.Set("channel_id", channel)
+	} else {
+		q.Set("user", channel)
+	}
+	u.RawQuery = q.Encode()
+
+	fc.Lock()
+	state, cached := fc.feeds[channel]
+	fc.Unlock()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if cached {
+		if state.etag != "" {
+			req.Header.Set("If-None-Match", state.etag)
+		}
+		if state.lastModified != "" {
+			req.Header.Set("If-Modified-Since", state.lastModified)
+		}
+	}
+	resp, err := fc.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified && cached {
+		return state.entries, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("Invalid status code: %d", resp.StatusCode)
+	}
+	entries, err := ParseFeed(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	fc.Lock()
+	fc.feeds[channel] = feedState{
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+		entries:      entries,
+	}
+	fc.Unlock()
+	return entries, nil
+}
+
+// ParseFeed parses Atom feed of channel uploads
+func ParseFeed(r io.Reader) ([]FeedEntry, error) {
+	var feed atomFeed
+	if err := xml.NewDecoder(r).Decode(&feed); err != nil {
+		return nil, err
+	}
+	entries := make([]FeedEntry, 0, len(feed.Entries))
+	for _, e := range feed.Entries {
+		if e.VideoID == "" {
+			continue
+		}
+		published, _ := time.Parse(time.RFC3339, e.Published)
+		updated, _ := time.Parse(time.RFC3339, e.Updated)
+		entries = append(entries, FeedEntry{
+			VideoID:   e.VideoID,
+			ChannelID: e.ChannelID,
+			Title:     e.Title,
+			Published: published,
+			Updated:   updated,
+		})
+	}
+	return entries, nil
+}
+
+// isChannelID returns true if value looks like YouTube channel ID
+func isChannelID(value string) bool {
+	return len(value) == 24 && strings.HasPrefix(value, "UC")
+}
@@ -0,0 +1,125 @@
+package bot
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns:yt="http://www.youtube.com/xml/schemas/2015" xmlns="http://www.w3.org/2005/Atom">
+ <title>Channel</title>
+ <entry>
+  <yt:videoId>video1</yt:videoId>
+  <yt:channelId>UCabcdefghijklmnopqrstuv</yt:channelId>
+  <title>First video</title>
+  <published>2026-10-18T10:00:00+00:00</published>
+  <updated>2026-10-18T10:05:00+00:00</updated>
+ </entry>
+ <entry>
+  <title>Entry without video</title>
+ </entry>
+ <entry>
+  <yt:videoId>video2</yt:videoId>
+  <yt:channelId>UCabcdefghijklmnopqrstuv</yt:channelId>
+  <title>Second video</title>
+  <published>2026-10-17T10:00:00+00:00</published>
+ </entry>
+</feed>`
+
+// feedStandIn serves uploads feed like YouTube does, with ETag support
+type feedStandIn struct {
+	sync.Mutex
+	queries []string
+	status  int
+	body    string
+}
+
+func (f *feedStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	f.queries = append(f.queries, r.URL.RawQuery)
+	if f.status != 0 {
+		w.WriteHeader(f.status)
+		return
+	}
+	if r.Header.Get("If-None-Match") == `"v1"` {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", `"v1"`)
+	fmt.Fprint(w, f.body)
+}
+
+func TestFeedClientChannel(t *testing.T) {
+	feed := &feedStandIn{body: testFeed}
+	server := httptest.NewServer(feed)
+	defer server.Close()
+	fc := NewFeedClient(server.URL)
+
+	entries, err := fc.Channel("UCabcdefghijklmnopqrstuv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	first := entries[0]
+	if first.VideoID != "video1" || first.ChannelID != "UCabcdefghijklmnopqrstuv" || first.Title != "First video" {
+		t.Fatalf("unexpected entry %+v", first)
+	}
+	if !first.Published.Equal(time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)) ||
+		!first.Updated.Equal(time.Date(2026, 10, 18, 10, 5, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected times %+v", first)
+	}
+	if feed.queries[0] != "channel_id=UCabcdefghijklmnopqrstuv" {
+		t.Fatalf("unexpected query %q", feed.queries[0])
+	}
+
+	cached, err := fc.Channel("UCabcdefghijklmnopqrstuv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cached) != 2 {
+		t.Fatalf("not modified feed must return cached entries, got %d", len(cached))
+	}
+
+	if _, err := fc.Channel("someuser"); err != nil {
+		t.Fatal(err)
+	}
+	if feed.queries[2] != "user=someuser" {
+		t.Fatalf("username must be requested by user, got %q", feed.queries[2])
+	}
+}
+
+func TestFeedClientErrors(t *testing.T) {
+	feed := &feedStandIn{status: http.StatusNotFound}
+	server := httptest.NewServer(feed)
+	fc := NewFeedClient(server.URL)
+	if _, err := fc.Channel("UCabcdefghijklmnopqrstuv"); err == nil {
+		t.Fatal("expected error for unknown channel")
+	}
+
+	feed.Lock()
+	feed.status = http.StatusInternalServerError
+	feed.Unlock()
+	if _, err := fc.Channel("UCabcdefghijklmnopqrstuv"); err == nil {
+		t.Fatal("expected error for server error")
+	}
+
+	feed.Lock()
+	feed.status = 0
+	feed.body = "<feed><entry>"
+	feed.Unlock()
+	if _, err := fc.Channel("UCzzzzzzzzzzzzzzzzzzzzzz"); err == nil {
+		t.Fatal("expected error for malformed feed")
+	}
+
+	server.Close()
+	if _, err := fc.Channel("UCabcdefghijklmnopqrstuv"); err == nil {
+		t.Fatal("expected error for unreachable feed")
+	}
+}
@@ -0,0 +1,104 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// SearchFilter stores search parameters supported by YouTube Data API.
+// Empty fields are not sent
+type SearchFilter struct {
+	Lookback          time.Duration
+	Duration          string
+	RelevanceLanguage string
+	RegionCode        string
+	SafeSearch        string
+	Definition        string
+	Caption           string
+	License           string
+}
+
+// isVideoOnly returns true if filter uses parameters that require type=video
+func (f SearchFilter) isVideoOnly() bool {
+	return f.Duration != "" || f.Definition != "" || f.Caption != "" || f.License != ""
+}
+
+func (f SearchFilter) apply(call *youtube.SearchListCall, defaultLookback time.Duration) *youtube.SearchListCall {
+	lookback := f.Lookback
+	if lookback == 0 {
+		lookback = defaultLookback
+	}
+	if lookback > 0 {
+		call = call.PublishedAfter(time.Now().Add(-lookback).Format(time.RFC3339))
+	}
+	if f.isVideoOnly() {
+		call = call.Type("video")
+	}
+	if f.Duration != "" {
+		call = call.VideoDuration(f.Duration)
+	}
+	if f.RelevanceLanguage != "" {
+		call = call.RelevanceLanguage(f.RelevanceLanguage)
+	}
+	if f.RegionCode != "" {
+		call = call.RegionCode(f.RegionCode)
+	}
+	if f.SafeSearch != "" {
+		call = call.SafeSearch(f.SafeSearch)
+	}
+	if f.Definition != "" {
+		call = call.VideoDefinition(f.Definition)
+	}
+	if f.Caption != "" {
+		call = call.VideoCaption(f.Caption)
+	}
+	if f.License != "" {
+		call = call.VideoLicense(f.License)
+	}
+	return call
+}
+
+// key returns part of cache key for filter
+func (f SearchFilter) key() string {
+	return strings.Join([]string{
+		fmt.Sprintf("%d", int64(f.Lookback/time.Second)),
+		f.Duration, f.RelevanceLanguage, f.RegionCode,
+		f.SafeSearch, f.Definition, f.Caption, f.License,
+	}, ":")
+}
+
+// ParseDuration parses ISO 8601 duration used by YouTube, e.g. PT1H2M3S
+func ParseDuration(value string) (time.Duration, error) {
+	if !strings.HasPrefix(value, "P") {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	var d time.Duration
+	var n int64
+	inTime := false
+	for _, r := range value[1:] {
+		switch {
+		case r >= '0' && r <= '9':
+			n = n*10 + int64(r-'0')
+			continue
+		case r == 'T':
+			inTime = true
+		case r == 'W':
+			d += time.Duration(n) * 7 * 24 * time.Hour
+		case r == 'D':
+			d += time.Duration(n) * 24 * time.Hour
+		case r == 'H' && inTime:
+			d += time.Duration(n) * time.Hour
+		case r == 'M' && inTime:
+			d += time.Duration(n) * time.Minute
+		case r == 'S' && inTime:
+			d += time.Duration(n) * time.Second
+		default:
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		n = 0
+	}
+	return d, nil
+}
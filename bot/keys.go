@@ -0,0 +1,279 @@
+package bot
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeyActive, KeyExhausted, KeyRateLimited and KeyBenched are health states of API key
+const (
+	KeyActive      = "active"
+	KeyExhausted   = "exhausted"
+	KeyRateLimited = "rate_limited"
+	KeyBenched     = "benched"
+)
+
+// rateLimitCooldown is how long key rests after rateLimitExceeded error
+const rateLimitCooldown = time.Minute
+
+// authBenchDuration is how long key is benched after auth error
+const authBenchDuration = 30 * time.Minute
+
+// ErrNoHealthyKeys is returned when every key in pool is exhausted or benched
+var ErrNoHealthyKeys = errors.New("no healthy youtube api keys")
+
+// KeyStatus describes usage and health of API key
+type KeyStatus struct {
+	Key       string    `json:"key"`
+	State     string    `json:"state"`
+	Until     time.Time `json:"until,omitempty"`
+	Calls     int       `json:"calls"`
+	Units     int       `json:"units"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+type apiKey struct {
+	key       string
+	state     string
+	until     time.Time
+	calls     int
+	units     int
+	lastError string
+}
+
+func (k *apiKey) healthy(now time.Time) bool {
+	return k.state == KeyActive || now.After(k.until)
+}
+
+// KeyPool rotates API keys on quota errors and benches keys with auth errors
+type KeyPool struct {
+	sync.Mutex
+	keys    []*apiKey
+	current int
+	day     string
+}
+
+// NewKeyPool creates pool of keys, empty keys are skipped
+func NewKeyPool(keys []string) *KeyPool {
+	p := &KeyPool{day: today()}
+	for _, key := range keys {
+		p.Add(key)
+	}
+	return p
+}
+
+// Add adds key into pool, returns false if key is empty or already exists
+func (p *KeyPool) Add(key string) bool {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return false
+	}
+	p.Lock()
+	defer p.Unlock()
+	for _, k := range p.keys {
+		if k.key == key {
+			return false
+		}
+	}
+	p.keys = append(p.keys, &apiKey{key: key, state: KeyActive})
+	return true
+}
+
+// Remove removes key from pool, returns false if key was not found
+func (p *KeyPool) Remove(key string) bool {
+	p.Lock()
+	defer p.Unlock()
+	for i, k := range p.keys {
+		if k.key == key {
+			p.keys = append(p.keys[:i], p.keys[i+1:]...)
+			if p.current >= len(p.keys) {
+				p.current = 0
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns number of keys in pool
+func (p *KeyPool) Len() int {
+	p.Lock()
+	defer p.Unlock()
+	return len(p.keys)
+}
+
+// Status returns usage and health of every key. Keys are masked
+func (p *KeyPool) Status() []KeyStatus {
+	p.Lock()
+	defer p.Unlock()
+	p.rotateDay()
+	now := time.Now()
+	statuses := make([]KeyStatus, 0, len(p.keys))
+	for _, k := range p.keys {
+		status := KeyStatus{
+			Key:       maskKey(k.key),
+			State:     k.state,
+			Calls:     k.calls,
+			Units:     k.units,
+			LastError: k.lastError,
+		}
+		if k.healthy(now) {
+			status.State = KeyActive
+		} else {
+			status.Until = k.until
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// rotateDay resets usage counters and exhausted keys when quota day changes
+func (p *KeyPool) rotateDay() {
+	day := today()
+	if day == p.day {
+		return
+	}
+	p.day = day
+	for _, k := range p.keys {
+		k.calls = 0
+		k.units = 0
+		if k.state == KeyExhausted {
+			k.state = KeyActive
+		}
+	}
+}
+
+// next returns current key if it's healthy or moves to next healthy one
+func (p *KeyPool) next() (*apiKey, error) {
+	p.Lock()
+	defer p.Unlock()
+	p.rotateDay()
+	now := time.Now()
+	for i := 0; i < len(p.keys); i++ {
+		idx := (p.current + i) % len(p.keys)
+		if k := p.keys[idx]; k.healthy(now) {
+			k.state = KeyActive
+			p.current = idx
+			return k, nil
+		}
+	}
+	return nil, ErrNoHealthyKeys
+}
+
+func (p *KeyPool) record(k *apiKey, units int) {
+	p.Lock()
+	k.calls++
+	k.units += units
+	p.Unlock()
+}
+
+// report marks key by error reason and returns true if request should be
+// retried with another key
+func (p *KeyPool) report(k *apiKey, status int, reason string) bool {
+	p.Lock()
+	defer p.Unlock()
+	now := time.Now()
+	switch {
+	case reason == "quotaExceeded" || reason == "dailyLimitExceeded":
+		k.state = KeyExhausted
+		k.until = nextQuotaDay(now)
+	case reason == "rateLimitExceeded" || reason == "userRateLimitExceeded":
+		k.state = KeyRateLimited
+		k.until = now.Add(rateLimitCooldown)
+	case status == http.StatusUnauthorized,
+		reason == "keyInvalid", reason == "keyExpired",
+		reason == "accessNotConfigured", reason == "ipRefererBlocked",
+		reason == "forbidden" && status == http.StatusForbidden:
+		k.state = KeyBenched
+		k.until = now.Add(authBenchDuration)
+	default:
+		return false
+	}
+	k.lastError = reason
+	if reason == "" {
+		k.lastError = http.StatusText(status)
+	}
+	return true
+}
+
+func nextQuotaDay(now time.Time) time.Time {
+	t := now.In(quotaLocation)
+	return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, quotaLocation)
+}
+
+func maskKey(key string) string {
+	if len(key) <= 8 {
+		return strings.Repeat("*", len(key))
+	}
+	return key[:4] + strings.Repeat("*", len(key)-8) + key[len(key)-4:]
+}
+
+// costByPath returns quota units spent by API call
+func costByPath(path string) int {
+	if strings.HasSuffix(path, "/search") {
+		return CostSearch
+	}
+	return CostChannels
+}
+
+// keyTransport adds key from pool to every request and retries request
+// with another key when current one is exhausted
+type keyTransport struct {
+	pool *KeyPool
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *keyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		k, err := t.pool.next()
+		if err != nil {
+			return nil, err
+		}
+		r := new(http.Request)
+		*r = *req
+		u := *req.URL
+		q := u.Query()
+		q.Set("key", k.key)
+		u.RawQuery = q.Encode()
+		r.URL = &u
+
+		resp, err := t.base.RoundTrip(r)
+		if err != nil {
+			return nil, err
+		}
+		t.pool.record(k, costByPath(req.URL.Path))
+		if resp.StatusCode < 400 {
+			return resp, nil
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+		if !t.pool.report(k, resp.StatusCode, errorReason(body)) || attempt+1 >= t.pool.Len() {
+			return resp, nil
+		}
+	}
+}
+
+func errorReason(body []byte) string {
+	var apiErr struct {
+		Error struct {
+			Errors []struct {
+				Reason string `json:"reason"`
+			} `json:"errors"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &apiErr); err != nil || len(apiErr.Error.Errors) == 0 {
+		return ""
+	}
+	return apiErr.Error.Errors[0].Reason
+}
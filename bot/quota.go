@@ -0,0 +1,151 @@
+package bot
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// CostSearch, CostChannels, CostPlaylistItems and CostVideos are quota units
+// spent by YouTube Data API calls
+const (
+	CostSearch        = 100
+	CostChannels      = 1
+	CostPlaylistItems = 1
+	CostVideos        = 1
+)
+
+// reservePercent is a share of budget that deep searches can't spend
+const reservePercent = 20
+
+// ErrQuotaExhausted is returned when daily budget does not allow a call
+// and there is no cached response to fall back to
+var ErrQuotaExhausted = errors.New("youtube quota budget exhausted")
+
+// quotaLocation is a timezone where YouTube resets daily quota
+var quotaLocation = loadQuotaLocation()
+
+func loadQuotaLocation() *time.Location {
+	loc, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		return time.FixedZone("PST", -8*60*60)
+	}
+	return loc
+}
+
+// QuotaUsage is a snapshot of quota spent today
+type QuotaUsage struct {
+	Day       string         `json:"day"`
+	Budget    int            `json:"budget"`
+	Spent     int            `json:"spent"`
+	Remaining int            `json:"remaining"`
+	Calls     map[string]int `json:"calls"`
+	Units     map[string]int `json:"units"`
+	Stale     int            `json:"stale_responses"`
+}
+
+// Quota tracks units spent per call type against daily budget
+type Quota struct {
+	sync.Mutex
+	budget int
+	day    string
+	calls  map[string]int
+	units  map[string]int
+	stale  int
+}
+
+// NewQuota creates quota with daily budget. Zero budget means unlimited
+func NewQuota(budget int) *Quota {
+	q := &Quota{budget: budget}
+	q.reset(today())
+	return q
+}
+
+func today() string {
+	return time.Now().In(quotaLocation).Format("2006-01-02")
+}
+
+func (q *Quota) reset(day string) {
+	q.day = day
+	q.calls = make(map[string]int)
+	q.units = make(map[string]int)
+	q.stale = 0
+}
+
+func (q *Quota) rotate() {
+	if day := today(); day != q.day {
+		q.reset(day)
+	}
+}
+
+func (q *Quota) spent() int {
+	total := 0
+	for _, units := range q.units {
+		total += units
+	}
+	return total
+}
+
+// SetBudget changes daily budget
+func (q *Quota) SetBudget(budget int) {
+	q.Lock()
+	q.budget = budget
+	q.Unlock()
+}
+
+// CanSpend returns true if budget allows to spend units
+func (q *Quota) CanSpend(units int) bool {
+	q.Lock()
+	defer q.Unlock()
+	q.rotate()
+	return q.budget <= 0 || q.spent()+units <= q.budget
+}
+
+// Reserve returns units that should be left for regular calls
+func (q *Quota) Reserve() int {
+	q.Lock()
+	defer q.Unlock()
+	return q.budget * reservePercent / 100
+}
+
+// Spend records call that spent units
+func (q *Quota) Spend(call string, units int) {
+	q.Lock()
+	q.rotate()
+	q.calls[call]++
+	q.units[call] += units
+	q.Unlock()
+}
+
+// ServedStale records response served from stale cache
+func (q *Quota) ServedStale() {
+	q.Lock()
+	q.rotate()
+	q.stale++
+	q.Unlock()
+}
+
+// Usage returns current quota usage
+func (q *Quota) Usage() QuotaUsage {
+	q.Lock()
+	defer q.Unlock()
+	q.rotate()
+	usage := QuotaUsage{
+		Day:    q.day,
+		Budget: q.budget,
+		Spent:  q.spent(),
+		Calls:  make(map[string]int),
+		Units:  make(map[string]int),
+		Stale:  q.stale,
+	}
+	for call, count := range q.calls {
+		usage.Calls[call] = count
+	}
+	for call, units := range q.units {
+		usage.Units[call] = units
+	}
+	if q.budget > 0 {
+		usage.Remaining = q.budget - usage.Spent
+	}
+	return usage
+}
@@ -1,89 +1,297 @@
 package bot
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
-	"google.golang.org/api/googleapi/transport"
 	"google.golang.org/api/youtube/v3"
 )
 
+// DefaultSearchDepth is number of results collected by search when depth is not set
+const DefaultSearchDepth = 50
+
+// maxPageSize is maximum number of results per page allowed by YouTube Data API
+const maxPageSize = 50
+
 // YoutubeClient stores copy of youtube service
 type YoutubeClient struct {
 	youtubeService *youtube.Service
+
+	keys     *KeyPool
+	keyQuota int
+	quota    *Quota
+	cache    *responseCache
+
+	channelsMu sync.RWMutex
+	channelIDs map[string]string
 }
 
-// NewYoutubeClient creates client for youtube
-func NewYoutubeClient(devKey string) (*YoutubeClient, error) {
+// NewYoutubeClient creates client for youtube. Requests are made with keys
+// from pool, dailyQuota is a budget of quota units per key per day,
+// responses are cached for cacheTTL
+func NewYoutubeClient(devKeys []string, dailyQuota int, cacheTTL time.Duration) (*YoutubeClient, error) {
+	yc := new(YoutubeClient)
+	yc.keys = NewKeyPool(devKeys)
 	client := &http.Client{
-		Transport: &transport.APIKey{Key: devKey},
+		Transport: &keyTransport{pool: yc.keys, base: http.DefaultTransport},
 	}
-	yc := new(YoutubeClient)
 	service, err := youtube.New(client)
 	if err != nil {
 		return nil, err
 	}
 	yc.youtubeService = service
+	yc.keyQuota = dailyQuota
+	yc.quota = NewQuota(dailyQuota * yc.keys.Len())
+	yc.cache = newResponseCache(cacheTTL)
+	yc.channelIDs = make(map[string]string)
 	return yc, nil
 }
 
-// Search performs query to youtube and return results
-func (yc *YoutubeClient) Search(query string) ([]*youtube.SearchResult, error) {
-	time := time.Now().AddDate(0, 0, -2).Format(time.RFC3339)
+// QuotaUsage returns quota spent today
+func (yc *YoutubeClient) QuotaUsage() QuotaUsage {
+	return yc.quota.Usage()
+}
+
+// AddKey adds API key into pool at runtime
+func (yc *YoutubeClient) AddKey(key string) bool {
+	added := yc.keys.Add(key)
+	yc.quota.SetBudget(yc.keyQuota * yc.keys.Len())
+	return added
+}
+
+// RemoveKey removes API key from pool at runtime
+func (yc *YoutubeClient) RemoveKey(key string) bool {
+	removed := yc.keys.Remove(key)
+	yc.quota.SetBudget(yc.keyQuota * yc.keys.Len())
+	return removed
+}
+
+// KeyStatus returns usage and health of every API key
+func (yc *YoutubeClient) KeyStatus() []KeyStatus {
+	return yc.keys.Status()
+}
+
+// Search performs query to youtube and return up to depth results.
+// Videos published in last 2 days are returned unless filter sets lookback
+func (yc *YoutubeClient) Search(query string, depth int, filter SearchFilter) ([]*youtube.SearchResult, error) {
 	call := yc.youtubeService.Search.List("id,snippet").
 		Q(query).
-		MaxResults(50).
-		PublishedAfter(time).
 		Order("date")
-	response, err := call.Do()
+	call = filter.apply(call, 2*24*time.Hour)
+	return yc.search(fmt.Sprintf("search:%s:%d:%s", query, depth, filter.key()), depth, call)
+}
+
+// SearchOnChannel performs query to youtube and return up to depth results
+func (yc *YoutubeClient) SearchOnChannel(query string, depth int, filter SearchFilter) ([]*youtube.SearchResult, error) {
+	searchQuery, err := yc.channelID(query)
 	if err != nil {
 		return nil, err
 	}
-	return response.Items, nil
+	call := yc.youtubeService.Search.List("id,snippet").
+		ChannelId(searchQuery).
+		Order("date")
+	call = filter.apply(call, 0)
+	return yc.search(fmt.Sprintf("channel:%s:%d:%s", searchQuery, depth, filter.key()), depth, call)
 }
 
-// SearchOnChannel performs query to youtube and return results
-func (yc *YoutubeClient) SearchOnChannel(query string) ([]*youtube.SearchResult, error) {
-	channelCall := yc.youtubeService.Channels.List("id").ForUsername(query)
-	response, err := channelCall.Do()
+// SearchOnChannelByTime performs query to youtube and return up to depth results.
+// Videos published in last day are returned unless filter sets lookback
+func (yc *YoutubeClient) SearchOnChannelByTime(query string, depth int, filter SearchFilter) ([]*youtube.SearchResult, error) {
+	searchQuery, err := yc.channelID(query)
 	if err != nil {
 		return nil, err
 	}
-	searchQuery := query
-	if len(response.Items) > 0 {
-		searchQuery = response.Items[0].Id
-	}
 	call := yc.youtubeService.Search.List("id,snippet").
 		ChannelId(searchQuery).
-		MaxResults(50).
 		Order("date")
-	ChannelResponse, err := call.Do()
-	if err != nil {
-		return nil, err
+	call = filter.apply(call, 24*time.Hour)
+	return yc.search(fmt.Sprintf("channel_by_time:%s:%d:%s", searchQuery, depth, filter.key()), depth, call)
+}
+
+// SearchLive returns up to depth broadcasts that are live now, found by
+// query or on channel. Lookback of filter is ignored
+func (yc *YoutubeClient) SearchLive(query string, isChannel bool, depth int, filter SearchFilter) ([]*youtube.SearchResult, error) {
+	call := yc.youtubeService.Search.List("id,snippet").
+		EventType("live").
+		Type("video")
+	key := "live_search:" + query
+	if isChannel {
+		channelID, err := yc.channelID(query)
+		if err != nil {
+			return nil, err
+		}
+		call = call.ChannelId(channelID)
+		key = "live_channel:" + channelID
+	} else {
+		call = call.Q(query)
 	}
-	return ChannelResponse.Items, nil
+	filter.Lookback = 0
+	call = filter.apply(call, 0)
+	return yc.search(fmt.Sprintf("%s:%d:%s", key, depth, filter.key()), depth, call)
 }
 
-// SearchOnChannelByTime performs query to youtube and return results
-func (yc *YoutubeClient) SearchOnChannelByTime(query string) ([]*youtube.SearchResult, error) {
+// Videos returns details of videos by ids. Details are cached per video
+func (yc *YoutubeClient) Videos(ids []string) ([]*youtube.Video, error) {
+	var videos []*youtube.Video
+	var missing []string
+	for _, id := range ids {
+		if cached, fresh, ok := yc.cache.get("video:" + id); ok && fresh {
+			videos = append(videos, cached.(*youtube.Video))
+			continue
+		}
+		missing = append(missing, id)
+	}
+	for start := 0; start < len(missing); start += maxPageSize {
+		end := start + maxPageSize
+		if end > len(missing) {
+			end = len(missing)
+		}
+		if !yc.quota.CanSpend(CostVideos) {
+			return nil, ErrQuotaExhausted
+		}
+		call := yc.youtubeService.Videos.List("contentDetails,statistics,snippet").
+			Id(strings.Join(missing[start:end], ","))
+		response, err := call.Do()
+		yc.quota.Spend("videos", CostVideos)
+		if err != nil {
+			return nil, err
+		}
+		for _, video := range response.Items {
+			yc.cache.set("video:"+video.Id, video)
+			videos = append(videos, video)
+		}
+	}
+	return videos, nil
+}
+
+// search pages through search call until depth results are collected,
+// using cached results when they are fresh or when quota budget does not
+// allow the first call. Expired results are served when the first call
+// fails too, e.g. API reports exceeded quota. Next pages are requested only while budget stays
+// above reserve, otherwise search is cut off with results collected so far
+func (yc *YoutubeClient) search(key string, depth int, call *youtube.SearchListCall) ([]*youtube.SearchResult, error) {
+	cached, fresh, ok := yc.cache.get(key)
+	if ok && fresh {
+		return cached.([]*youtube.SearchResult), nil
+	}
+	if depth <= 0 {
+		depth = DefaultSearchDepth
+	}
+	var items []*youtube.SearchResult
+	pageToken := ""
+	for len(items) < depth {
+		if pageToken == "" && !yc.quota.CanSpend(CostSearch) {
+			if ok {
+				yc.quota.ServedStale()
+				return cached.([]*youtube.SearchResult), nil
+			}
+			return nil, ErrQuotaExhausted
+		}
+		if pageToken != "" {
+			if !yc.quota.CanSpend(CostSearch + yc.quota.Reserve()) {
+				break
+			}
+			call = call.PageToken(pageToken)
+		}
+		pageSize := depth - len(items)
+		if pageSize > maxPageSize {
+			pageSize = maxPageSize
+		}
+		response, err := call.MaxResults(int64(pageSize)).Do()
+		yc.quota.Spend("search", CostSearch)
+		if err != nil {
+			if len(items) > 0 {
+				break
+			}
+			if ok {
+				yc.quota.ServedStale()
+				return cached.([]*youtube.SearchResult), nil
+			}
+			return nil, err
+		}
+		items = append(items, response.Items...)
+		if response.NextPageToken == "" {
+			break
+		}
+		pageToken = response.NextPageToken
+	}
+	yc.cache.set(key, items)
+	return items, nil
+}
+
+// ChannelID returns channel ID for username or channel ID itself
+func (yc *YoutubeClient) ChannelID(query string) (string, error) {
+	if isChannelID(query) {
+		return query, nil
+	}
+	return yc.channelID(query)
+}
+
+// channelID returns channel ID for username. Lookups are cached permanently.
+// If username is not found query is used as channel ID
+func (yc *YoutubeClient) channelID(query string) (string, error) {
+	yc.channelsMu.RLock()
+	id, ok := yc.channelIDs[query]
+	yc.channelsMu.RUnlock()
+	if ok {
+		return id, nil
+	}
+	if !yc.quota.CanSpend(CostChannels) {
+		return "", ErrQuotaExhausted
+	}
 	channelCall := yc.youtubeService.Channels.List("id").ForUsername(query)
 	response, err := channelCall.Do()
-	time := time.Now().AddDate(0, 0, -1).Format(time.RFC3339)
+	yc.quota.Spend("channels", CostChannels)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	searchQuery := query
+	id = query
 	if len(response.Items) > 0 {
-		searchQuery = response.Items[0].Id
+		id = response.Items[0].Id
 	}
-	call := yc.youtubeService.Search.List("id,snippet").
-		ChannelId(searchQuery).
-		MaxResults(50).
-		PublishedAfter(time).
-		Order("date")
-	ChannelResponse, err := call.Do()
-	if err != nil {
-		return nil, err
+	yc.channelsMu.Lock()
+	yc.channelIDs[query] = id
+	yc.channelsMu.Unlock()
+	return id, nil
+}
+
+// PlaylistItems returns all items of playlist, following every result page
+func (yc *YoutubeClient) PlaylistItems(playlistID string) ([]*youtube.PlaylistItem, error) {
+	key := "playlist:" + playlistID
+	cached, fresh, ok := yc.cache.get(key)
+	if ok && fresh {
+		return cached.([]*youtube.PlaylistItem), nil
+	}
+	var items []*youtube.PlaylistItem
+	pageToken := ""
+	for {
+		if !yc.quota.CanSpend(CostPlaylistItems) {
+			if ok {
+				yc.quota.ServedStale()
+				return cached.([]*youtube.PlaylistItem), nil
+			}
+			return nil, ErrQuotaExhausted
+		}
+		call := yc.youtubeService.PlaylistItems.List("contentDetails,status").
+			PlaylistId(playlistID).
+			MaxResults(maxPageSize)
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
+		}
+		response, err := call.Do()
+		yc.quota.Spend("playlist_items", CostPlaylistItems)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, response.Items...)
+		if response.NextPageToken == "" {
+			break
+		}
+		pageToken = response.NextPageToken
 	}
-	return ChannelResponse.Items, nil
+	yc.cache.set(key, items)
+	return items, nil
 }
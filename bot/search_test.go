@@ -0,0 +1,72 @@
+package bot
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// searchStandIn serves search.list like YouTube Data API, failing with
+// exceeded quota once failing is set
+type searchStandIn struct {
+	sync.Mutex
+	requests int
+	failing  bool
+}
+
+func (s *searchStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+	s.requests++
+	w.Header().Set("Content-Type", "application/json")
+	if s.failing {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"error": {"code": 403, "message": "quota exceeded", "errors": [{"reason": "quotaExceeded"}]}}`)
+		return
+	}
+	fmt.Fprint(w, `{"items": [{"id": {"kind": "youtube#video", "videoId": "video1"}}]}`)
+}
+
+func TestSearchServesStaleOnAPIError(t *testing.T) {
+	api := &searchStandIn{}
+	server := httptest.NewServer(api)
+	defer server.Close()
+	yc, err := NewYoutubeClient([]string{"key"}, 10000, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	yc.youtubeService.BasePath = server.URL + "/"
+
+	results, err := yc.Search("news", 10, SearchFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Id.VideoId != "video1" {
+		t.Fatalf("unexpected results %v", results)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	api.Lock()
+	api.failing = true
+	api.Unlock()
+	stale, err := yc.Search("news", 10, SearchFilter{})
+	if err != nil {
+		t.Fatalf("expired results must be served on API error, got %v", err)
+	}
+	if len(stale) != 1 || stale[0].Id.VideoId != "video1" {
+		t.Fatalf("unexpected stale results %v", stale)
+	}
+	if api.requests != 2 {
+		t.Fatalf("expired results must be requested again, got %d requests", api.requests)
+	}
+	if usage := yc.QuotaUsage(); usage.Stale != 1 {
+		t.Fatalf("stale response must be counted, got %d", usage.Stale)
+	}
+
+	if _, err := yc.Search("weather", 10, SearchFilter{}); err == nil {
+		t.Fatal("expected error when nothing is cached")
+	}
+}
@@ -0,0 +1,120 @@
+package bot
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultHubURL is url of hub used by YouTube for push notifications
+const DefaultHubURL = "https://pubsubhubbub.appspot.com/subscribe"
+
+// topicURL is feed url that YouTube publishes to hub
+const topicURL = "https://www.youtube.com/xml/feeds/videos.xml?channel_id="
+
+// WebSubClient subscribes callback to channel uploads on WebSub hub
+type WebSubClient struct {
+	hubURL       string
+	callbackURL  string
+	secret       string
+	leaseSeconds int
+	client       *http.Client
+}
+
+// NewWebSubClient creates client. DefaultHubURL is used if hubURL is empty
+func NewWebSubClient(hubURL, callbackURL, secret string, leaseSeconds int) *WebSubClient {
+	if hubURL == "" {
+		hubURL = DefaultHubURL
+	}
+	return &WebSubClient{
+		hubURL:       hubURL,
+		callbackURL:  callbackURL,
+		secret:       secret,
+		leaseSeconds: leaseSeconds,
+		client:       &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Topic returns topic url for channel
+func Topic(channelID string) string {
+	return topicURL + channelID
+}
+
+// ChannelByTopic returns channel ID from topic url
+func ChannelByTopic(topic string) string {
+	u, err := url.Parse(topic)
+	if err != nil {
+		return ""
+	}
+	return u.Query().Get("channel_id")
+}
+
+// Subscribe asks hub to subscribe callback to channel uploads.
+// Hub confirms subscription asynchronously by verification request
+func (wc *WebSubClient) Subscribe(channelID string) error {
+	return wc.request("subscribe", channelID)
+}
+
+// Unsubscribe asks hub to unsubscribe callback from channel uploads
+func (wc *WebSubClient) Unsubscribe(channelID string) error {
+	return wc.request("unsubscribe", channelID)
+}
+
+func (wc *WebSubClient) request(mode, channelID string) error {
+	form := url.Values{}
+	form.Set("hub.mode", mode)
+	form.Set("hub.topic", Topic(channelID))
+	form.Set("hub.callback", wc.callbackURL)
+	form.Set("hub.verify", "async")
+	if wc.leaseSeconds > 0 {
+		form.Set("hub.lease_seconds", strconv.Itoa(wc.leaseSeconds))
+	}
+	if wc.secret != "" {
+		form.Set("hub.secret", wc.secret)
+	}
+	resp, err := wc.client.PostForm(wc.hubURL, form)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("hub returned status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// VerifySignature checks X-Hub-Signature header of notification.
+// Nothing is accepted if secret is empty
+func VerifySignature(secret string, body []byte, header string) bool {
+	if secret == "" {
+		return false
+	}
+	parts := strings.SplitN(header, "=", 2)
+	if len(parts) != 2 {
+		return false
+	}
+	var h func() hash.Hash
+	switch parts[0] {
+	case "sha1":
+		h = sha1.New
+	case "sha256":
+		h = sha256.New
+	default:
+		return false
+	}
+	expected, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(h, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
@@ -1,13 +1,41 @@
 package conf
 
-// StreamerConfig stores service configuration
+// StreamerConfig stores service configuration. Settings tagged with
+// reload:"live" are applied on config file reload, others need restart
 type StreamerConfig struct {
-	RTMPRootServerURL string
-	WebUIURL          string
-	FFMpegPath        string
-	HTTPBindAddr      string
-	RootPath          string
-	YoutubeAPIKey     string
-	DisableStreaming  bool
-	DownloadLimit     int
+	ConfigPath        string `yaml:"-"`
+	LogLevel          string `yaml:"log_level" reload:"live"`
+	RTMPRootServerURL string `yaml:"rtmp_server_url" reload:"live"`
+	WebUIURL          string `yaml:"web_ui_url" reload:"live"`
+	HLSURL            string `yaml:"hls_url" reload:"live"`
+	FFMpegPath        string `yaml:"ffmpeg_path" reload:"live"`
+	FFProbePath       string `yaml:"ffprobe_path" reload:"live"`
+	Resolver          string `yaml:"resolver"`
+	ExtractorPath     string `yaml:"extractor_path"`
+	HTTPBindAddr      string `yaml:"http_bind_addr"`
+	RootPath          string `yaml:"root_path"`
+	QuarantinePath    string `yaml:"quarantine_path" reload:"live"`
+	ChannelsPath      string `yaml:"channels_file"`
+	ReconcileInterval int    `yaml:"reconcile_interval"`
+	YoutubeAPIKey     string `yaml:"youtube_api_key" secret:"true"`
+	YoutubeDailyQuota int    `yaml:"youtube_daily_quota"`
+	YoutubeCacheTTL   int    `yaml:"youtube_cache_ttl"`
+	YoutubeFeedURL    string `yaml:"youtube_feed_url"`
+	DisableStreaming  bool   `yaml:"disable_streaming"`
+	DownloadLimit     int    `yaml:"download_limit" reload:"live"`
+	CollisionWindow   int    `yaml:"collision_window"`
+	EmbedMetadata     bool   `yaml:"embed_metadata" reload:"live"`
+
+	WebSubHubURL       string `yaml:"websub_hub_url"`
+	WebSubCallbackURL  string `yaml:"websub_callback_url"`
+	WebSubSecret       string `yaml:"websub_secret" secret:"true"`
+	WebSubLeaseSeconds int    `yaml:"websub_lease_seconds"`
+
+	WebhookURLs   string `yaml:"webhook_urls" reload:"live"`
+	WebhookEvents string `yaml:"webhook_events" reload:"live"`
+	WebhookSecret string `yaml:"webhook_secret" reload:"live" secret:"true"`
+
+	APITokens        string `yaml:"api_tokens" reload:"live" secret:"true"`
+	APISigningSecret string `yaml:"api_signing_secret" reload:"live" secret:"true"`
+	AuditLogPath     string `yaml:"audit_log"`
 }
@@ -0,0 +1,125 @@
+package conf
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/gen1us2k/log"
+	"gopkg.in/yaml.v2"
+)
+
+// Change describes setting changed by config file reload
+type Change struct {
+	Name string      `json:"name"`
+	Old  interface{} `json:"old"`
+	New  interface{} `json:"new"`
+	Live bool        `json:"live"`
+}
+
+// Load reads YAML config file at path on top of base config.
+// Settings missing in file keep their values from base
+func Load(path string, base StreamerConfig) (StreamerConfig, error) {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return base, err
+	}
+	cfg := base
+	if err := yaml.UnmarshalStrict(body, &cfg); err != nil {
+		return base, err
+	}
+	cfg.ConfigPath = path
+	return cfg, nil
+}
+
+// Validate checks that config can be applied
+func (c *StreamerConfig) Validate() error {
+	if _, err := log.ParseLevel(c.LogLevel); err != nil {
+		return fmt.Errorf("log_level: %v", err)
+	}
+	for name, value := range map[string]string{
+		"rtmp_server_url": c.RTMPRootServerURL,
+		"web_ui_url":      c.WebUIURL,
+		"hls_url":         c.HLSURL,
+	} {
+		u, err := url.Parse(value)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("%s: invalid url %q", name, value)
+		}
+	}
+	for name, value := range map[string]string{
+		"ffmpeg_path":     c.FFMpegPath,
+		"root_path":       c.RootPath,
+		"quarantine_path": c.QuarantinePath,
+		"http_bind_addr":  c.HTTPBindAddr,
+	} {
+		if strings.TrimSpace(value) == "" {
+			return fmt.Errorf("%s: must not be empty", name)
+		}
+	}
+	if c.WebhookURLs != "" && c.WebhookSecret == "" {
+		return fmt.Errorf("webhook_secret: must be set when webhook_urls is set")
+	}
+	switch c.Resolver {
+	case "builtin", "extractor", "fallback":
+	default:
+		return fmt.Errorf("resolver: unknown resolver %q", c.Resolver)
+	}
+	for name, value := range map[string]int{
+		"download_limit":       c.DownloadLimit,
+		"youtube_daily_quota":  c.YoutubeDailyQuota,
+		"youtube_cache_ttl":    c.YoutubeCacheTTL,
+		"collision_window":     c.CollisionWindow,
+		"reconcile_interval":   c.ReconcileInterval,
+		"websub_lease_seconds": c.WebSubLeaseSeconds,
+	} {
+		if value < 0 {
+			return fmt.Errorf("%s: must not be negative", name)
+		}
+	}
+	return nil
+}
+
+// Diff returns settings that differ between configs. Secret values are masked
+func Diff(old, new StreamerConfig) []Change {
+	var changes []Change
+	ov := reflect.ValueOf(old)
+	nv := reflect.ValueOf(new)
+	t := ov.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("yaml")
+		if name == "" || name == "-" {
+			continue
+		}
+		o, n := ov.Field(i).Interface(), nv.Field(i).Interface()
+		if o == n {
+			continue
+		}
+		if field.Tag.Get("secret") == "true" {
+			o, n = "***", "***"
+		}
+		changes = append(changes, Change{
+			Name: name,
+			Old:  o,
+			New:  n,
+			Live: field.Tag.Get("reload") == "live",
+		})
+	}
+	return changes
+}
+
+// ApplyLive returns current config with live settings taken from next
+func ApplyLive(current, next StreamerConfig) StreamerConfig {
+	cv := reflect.ValueOf(&current).Elem()
+	nv := reflect.ValueOf(next)
+	t := cv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("reload") == "live" {
+			cv.Field(i).Set(nv.Field(i))
+		}
+	}
+	return current
+}
@@ -11,22 +11,72 @@ import (
 	"github.com/urfave/cli"
 )
 
+// ConfigFile used for configuration of YAML config file. Settings from file take precedence over flags
 // LogLevel used for logger configuration. For more detailed information see https://github.com/gen1us2k/log
 // RTMPRootServerURL used for configuration of url where to stream from youtube
 // WebUIURL used for configuration of http web api
+// HLSURL used for configuration of public url where HLS playlists of streams are served, used in M3U playlist
 // FFMpegPath used to store ffmpeg path to binary
+// FFProbePath used to store ffprobe path to binary, used for verification of downloaded files
+// Resolver used for selection of youtube resolver: builtin, extractor or fallback
+// ExtractorPath used to store path to yt-dlp compatible binary
 // HTTPBindAddr used for configuration of inner HTTP api where to bind to
+// YoutubeAPIKey used for configuration of YouTube Data API keys, comma separated
+// YoutubeDailyQuota used for configuration of daily budget of YouTube Data API quota units per key
+// YoutubeCacheTTL used for configuration of how long YouTube Data API responses are cached, in seconds
+// CollisionWindow used for configuration of time in seconds while the same video can't air on two channels, 0 disables it
+// YoutubeFeedURL used for configuration of url of YouTube channel uploads feed
+// WebSubHubURL used for configuration of hub where to subscribe for push notifications about uploads
+// WebSubCallbackURL used for configuration of public url of /websub endpoint, empty disables push notifications
+// WebSubSecret used for configuration of secret that signs push notifications
+// WebSubLeaseSeconds used for configuration of requested subscription lease
+// WebhookURLs used for configuration of comma separated urls where events are posted
+// WebhookEvents used for configuration of comma separated events sent to webhooks, e.g. item.*,download.failed, empty sends all
+// WebhookSecret used for configuration of secret that signs webhooks
+// APITokens used for configuration of comma separated token:scope pairs that protect HTTP api, scopes are read, control and admin
+// APISigningSecret used for configuration of secret that signs server-to-server requests of Web UI
+// AuditLogPath used for configuration of file where denied and state changing HTTP api requests are written
 // RootPath used for configuration where to store files, downloaded via ffmpeg while streaming
+// QuarantinePath used for configuration where to move downloaded files that failed verification
+// ChannelsFile used for configuration of JSON file with streams defined locally, merged with Web UI streams
+// EmbedMetadata used for configuration of embedding now-playing metadata into FLV output
+// ReconcileInterval used for configuration of how often streams are reconciled with Web UI, in seconds, 0 disables it
 var (
+	ConfigFile        string
 	LogLevel          string
 	RTMPRootServerURL string
 	WebUIURL          string
+	HLSURL            string
 	FFMpegPath        string
+	FFProbePath       string
+	Resolver          string
+	ExtractorPath     string
 	HTTPBindAddr      string
 	RootPath          string
+	QuarantinePath    string
+	ChannelsFile      string
+	ReconcileInterval int
 	YoutubeAPIKey     string
+	YoutubeDailyQuota int
+	YoutubeCacheTTL   int
+	YoutubeFeedURL    string
 	DownloadLimit     int
+	CollisionWindow   int
 	DisableStreaming  bool
+	EmbedMetadata     bool
+
+	WebSubHubURL       string
+	WebSubCallbackURL  string
+	WebSubSecret       string
+	WebSubLeaseSeconds int
+
+	WebhookURLs   string
+	WebhookEvents string
+	WebhookSecret string
+
+	APITokens        string
+	APISigningSecret string
+	AuditLogPath     string
 )
 
 func main() {
@@ -36,6 +86,11 @@ func main() {
 	app.Usage = "Restreams video directly from youtube"
 	app.Action = actionStart
 	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:        "config",
+			EnvVar:      "RESTREAMER_CONFIG",
+			Destination: &ConfigFile,
+		},
 
 		cli.IntFlag{
 			Name:        "download_limit",
@@ -43,6 +98,12 @@ func main() {
 			EnvVar:      "RESTREAMER_DOWNLOAD_LIMIT",
 			Destination: &DownloadLimit,
 		},
+		cli.IntFlag{
+			Name:        "collision_window",
+			Value:       0,
+			EnvVar:      "RESTREAMER_COLLISION_WINDOW",
+			Destination: &CollisionWindow,
+		},
 		cli.StringFlag{
 			Name:        "rtmp_server_url",
 			Value:       "rtmp://localhost/hls",
@@ -61,12 +122,36 @@ func main() {
 			EnvVar:      "RESTREAMER_WEB_UI_URL",
 			Destination: &WebUIURL,
 		},
+		cli.StringFlag{
+			Name:        "hls_url",
+			Value:       "http://localhost/hls",
+			EnvVar:      "RESTREAMER_HLS_URL",
+			Destination: &HLSURL,
+		},
 		cli.StringFlag{
 			Name:        "ffmpeg_path",
 			Value:       "/usr/local/bin/ffmpeg",
 			EnvVar:      "RESTREAMER_FFMPEG_PATH",
 			Destination: &FFMpegPath,
 		},
+		cli.StringFlag{
+			Name:        "ffprobe_path",
+			Value:       "/usr/local/bin/ffprobe",
+			EnvVar:      "RESTREAMER_FFPROBE_PATH",
+			Destination: &FFProbePath,
+		},
+		cli.StringFlag{
+			Name:        "resolver",
+			Value:       "builtin",
+			EnvVar:      "RESTREAMER_RESOLVER",
+			Destination: &Resolver,
+		},
+		cli.StringFlag{
+			Name:        "extractor_path",
+			Value:       "/usr/local/bin/yt-dlp",
+			EnvVar:      "RESTREAMER_EXTRACTOR_PATH",
+			Destination: &ExtractorPath,
+		},
 		cli.StringFlag{
 			Name:        "http_bind_addr",
 			Value:       ":8080",
@@ -79,17 +164,110 @@ func main() {
 			EnvVar:      "RESTREAMER_YOUTUBE_API_KEY",
 			Destination: &YoutubeAPIKey,
 		},
+		cli.IntFlag{
+			Name:        "youtube_daily_quota",
+			Value:       10000,
+			EnvVar:      "RESTREAMER_YOUTUBE_DAILY_QUOTA",
+			Destination: &YoutubeDailyQuota,
+		},
+		cli.IntFlag{
+			Name:        "youtube_cache_ttl",
+			Value:       900,
+			EnvVar:      "RESTREAMER_YOUTUBE_CACHE_TTL",
+			Destination: &YoutubeCacheTTL,
+		},
+		cli.StringFlag{
+			Name:        "youtube_feed_url",
+			Value:       "https://www.youtube.com/feeds/videos.xml",
+			EnvVar:      "RESTREAMER_YOUTUBE_FEED_URL",
+			Destination: &YoutubeFeedURL,
+		},
+		cli.StringFlag{
+			Name:        "websub_hub_url",
+			Value:       "https://pubsubhubbub.appspot.com/subscribe",
+			EnvVar:      "RESTREAMER_WEBSUB_HUB_URL",
+			Destination: &WebSubHubURL,
+		},
+		cli.StringFlag{
+			Name:        "websub_callback_url",
+			EnvVar:      "RESTREAMER_WEBSUB_CALLBACK_URL",
+			Destination: &WebSubCallbackURL,
+		},
+		cli.StringFlag{
+			Name:        "websub_secret",
+			EnvVar:      "RESTREAMER_WEBSUB_SECRET",
+			Destination: &WebSubSecret,
+		},
+		cli.IntFlag{
+			Name:        "websub_lease_seconds",
+			Value:       432000,
+			EnvVar:      "RESTREAMER_WEBSUB_LEASE_SECONDS",
+			Destination: &WebSubLeaseSeconds,
+		},
+		cli.StringFlag{
+			Name:        "webhook_urls",
+			EnvVar:      "RESTREAMER_WEBHOOK_URLS",
+			Destination: &WebhookURLs,
+		},
+		cli.StringFlag{
+			Name:        "webhook_events",
+			EnvVar:      "RESTREAMER_WEBHOOK_EVENTS",
+			Destination: &WebhookEvents,
+		},
+		cli.StringFlag{
+			Name:        "webhook_secret",
+			EnvVar:      "RESTREAMER_WEBHOOK_SECRET",
+			Destination: &WebhookSecret,
+		},
+		cli.StringFlag{
+			Name:        "api_tokens",
+			EnvVar:      "RESTREAMER_API_TOKENS",
+			Destination: &APITokens,
+		},
+		cli.StringFlag{
+			Name:        "api_signing_secret",
+			EnvVar:      "RESTREAMER_API_SIGNING_SECRET",
+			Destination: &APISigningSecret,
+		},
+		cli.StringFlag{
+			Name:        "audit_log",
+			Value:       "./storage/audit.log",
+			EnvVar:      "RESTREAMER_AUDIT_LOG",
+			Destination: &AuditLogPath,
+		},
 		cli.StringFlag{
 			Name:        "root_path",
 			Value:       "./storage",
 			EnvVar:      "RESTREAMER_FILE_ROOT_PATH",
 			Destination: &RootPath,
 		},
+		cli.StringFlag{
+			Name:        "quarantine_path",
+			Value:       "./storage/quarantine",
+			EnvVar:      "RESTREAMER_QUARANTINE_PATH",
+			Destination: &QuarantinePath,
+		},
+		cli.StringFlag{
+			Name:        "channels_file",
+			EnvVar:      "RESTREAMER_CHANNELS_FILE",
+			Destination: &ChannelsFile,
+		},
+		cli.IntFlag{
+			Name:        "reconcile_interval",
+			Value:       300,
+			EnvVar:      "RESTREAMER_RECONCILE_INTERVAL",
+			Destination: &ReconcileInterval,
+		},
 		cli.BoolFlag{
 			Name:        "disable_streaming",
 			EnvVar:      "RESTREAMER_DISABLE_STREAMING",
 			Destination: &DisableStreaming,
 		},
+		cli.BoolFlag{
+			Name:        "embed_metadata",
+			EnvVar:      "RESTREAMER_EMBED_METADATA",
+			Destination: &EmbedMetadata,
+		},
 	}
 	app.Before = func(ctx *cli.Context) error {
 		log.SetLevel(log.MustParseLevel(LogLevel))
@@ -102,23 +280,62 @@ func main() {
 }
 
 func actionStart(ctx *cli.Context) error {
-	log.Info("Checking storage directory")
-
-	if _, err := os.Stat(RootPath); os.IsNotExist(err) {
-		os.Mkdir(RootPath, 0755)
-	}
-	conf := &conf.StreamerConfig{
+	config := conf.StreamerConfig{
+		LogLevel:          LogLevel,
 		RTMPRootServerURL: RTMPRootServerURL,
 		WebUIURL:          WebUIURL,
+		HLSURL:            HLSURL,
 		FFMpegPath:        FFMpegPath,
+		FFProbePath:       FFProbePath,
+		Resolver:          Resolver,
+		ExtractorPath:     ExtractorPath,
 		HTTPBindAddr:      HTTPBindAddr,
 		RootPath:          RootPath,
+		QuarantinePath:    QuarantinePath,
+		ChannelsPath:      ChannelsFile,
+		ReconcileInterval: ReconcileInterval,
 		YoutubeAPIKey:     YoutubeAPIKey,
+		YoutubeDailyQuota: YoutubeDailyQuota,
+		YoutubeCacheTTL:   YoutubeCacheTTL,
+		YoutubeFeedURL:    YoutubeFeedURL,
 		DisableStreaming:  DisableStreaming,
+		EmbedMetadata:     EmbedMetadata,
 		DownloadLimit:     DownloadLimit,
+		CollisionWindow:   CollisionWindow,
+
+		WebSubHubURL:       WebSubHubURL,
+		WebSubCallbackURL:  WebSubCallbackURL,
+		WebSubSecret:       WebSubSecret,
+		WebSubLeaseSeconds: WebSubLeaseSeconds,
+
+		WebhookURLs:   WebhookURLs,
+		WebhookEvents: WebhookEvents,
+		WebhookSecret: WebhookSecret,
+
+		APITokens:        APITokens,
+		APISigningSecret: APISigningSecret,
+		AuditLogPath:     AuditLogPath,
+	}
+	if ConfigFile != "" {
+		log.Infof("Loading config file %s", ConfigFile)
+		loaded, err := conf.Load(ConfigFile, config)
+		if err != nil {
+			log.Fatalf("error on loading config file, %v", err)
+		}
+		config = loaded
+	}
+	if err := config.Validate(); err != nil {
+		log.Fatalf("invalid config, %v", err)
+	}
+	log.SetLevel(log.MustParseLevel(config.LogLevel))
+
+	log.Info("Checking storage directory")
+
+	if _, err := os.Stat(config.RootPath); os.IsNotExist(err) {
+		os.Mkdir(config.RootPath, 0755)
 	}
 	log.Info("Starting streamer...")
-	streamer := service.NewStreamer(conf)
+	streamer := service.NewStreamer(&config)
 
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, os.Interrupt, os.Kill)
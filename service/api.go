@@ -0,0 +1,208 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo"
+	"github.com/maddevsio/yourcast-streamer/service/data"
+)
+
+// apiPrefix is path prefix of versioned JSON API
+const apiPrefix = "/v1/"
+
+// maxRequestBody limits size of JSON request body
+const maxRequestBody = 1 << 20
+
+// APIError describes failed request of versioned API. Code is stable
+// machine-readable reason, Fields lists invalid fields of request body
+type APIError struct {
+	Status  int          `json:"status"`
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields,omitempty"`
+}
+
+// APIErrorResponse is error document returned by versioned API
+type APIErrorResponse struct {
+	Error APIError `json:"error"`
+}
+
+// codesByStatus are error codes of errors not raised by API handlers
+var codesByStatus = map[int]string{
+	http.StatusBadRequest:            "bad_request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusMethodNotAllowed:      "method_not_allowed",
+	http.StatusRequestEntityTooLarge: "body_too_large",
+	http.StatusUnsupportedMediaType:  "unsupported_media_type",
+	http.StatusInternalServerError:   "internal_error",
+}
+
+// apiError returns HTTP error carrying API error document
+func apiError(status int, code, message string, fields ...FieldError) *echo.HTTPError {
+	return echo.NewHTTPError(status, APIErrorResponse{Error: APIError{
+		Status:  status,
+		Code:    code,
+		Message: message,
+		Fields:  fields,
+	}})
+}
+
+// handleError writes errors of versioned API as error documents, other
+// errors are handled by echo
+func (h *HTTPService) handleError(err error, c echo.Context) {
+	if !strings.HasPrefix(c.Request().URL.Path, apiPrefix) {
+		h.e.DefaultHTTPErrorHandler(err, c)
+		return
+	}
+	he, ok := err.(*echo.HTTPError)
+	if !ok {
+		h.logger.Errorf("Got error %s while handling %s %s", err, c.Request().Method, c.Request().URL.Path)
+		he = echo.NewHTTPError(http.StatusInternalServerError)
+	}
+	if _, ok := he.Message.(APIErrorResponse); !ok {
+		code, ok := codesByStatus[he.Code]
+		if !ok {
+			code = strings.ToLower(strings.Replace(http.StatusText(he.Code), " ", "_", -1))
+		}
+		he = apiError(he.Code, code, fmt.Sprint(he.Message))
+	}
+	h.e.DefaultHTTPErrorHandler(he, c)
+}
+
+// decodeStream reads stream definition from JSON body. Unknown fields and
+// trailing data are rejected
+func decodeStream(c echo.Context) (data.Stream, error) {
+	var stream data.Stream
+	req := c.Request()
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get(echo.HeaderContentType))
+	if err != nil || mediaType != echo.MIMEApplicationJSON {
+		return stream, apiError(http.StatusUnsupportedMediaType, "unsupported_media_type", "request body must be application/json")
+	}
+	decoder := json.NewDecoder(http.MaxBytesReader(c.Response(), req.Body, maxRequestBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&stream); err != nil {
+		return stream, decodeError(err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return stream, apiError(http.StatusBadRequest, "invalid_json", "request body must contain single JSON object")
+	}
+	return stream, nil
+}
+
+func decodeError(err error) *echo.HTTPError {
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		return apiError(http.StatusBadRequest, "invalid_json", fmt.Sprintf("malformed JSON at offset %d", e.Offset))
+	case *json.UnmarshalTypeError:
+		return apiError(http.StatusBadRequest, "invalid_json", "request body has field of wrong type",
+			FieldError{Field: e.Field, Message: "must be " + e.Type.String()})
+	case *http.MaxBytesError:
+		return apiError(http.StatusRequestEntityTooLarge, "body_too_large", fmt.Sprintf("request body must not exceed %d bytes", maxRequestBody))
+	}
+	switch err {
+	case io.EOF:
+		return apiError(http.StatusBadRequest, "invalid_json", "request body is empty")
+	case io.ErrUnexpectedEOF:
+		return apiError(http.StatusBadRequest, "invalid_json", "request body is truncated")
+	}
+	if strings.HasPrefix(err.Error(), "json: unknown field ") {
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return apiError(http.StatusBadRequest, "invalid_json", "request body has unknown field",
+			FieldError{Field: field, Message: "is not allowed"})
+	}
+	return apiError(http.StatusBadRequest, "invalid_json", err.Error())
+}
+
+func validationError(err error) *echo.HTTPError {
+	fields, _ := err.(ValidationError)
+	return apiError(http.StatusUnprocessableEntity, "validation_failed", "stream is invalid", fields...)
+}
+
+func streamIDParam(c echo.Context) (int, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		return 0, apiError(http.StatusBadRequest, "invalid_id", "stream id must be positive integer")
+	}
+	return id, nil
+}
+
+func (h *HTTPService) listStreams(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.ys.Streams())
+}
+
+func (h *HTTPService) getStream(c echo.Context) error {
+	id, err := streamIDParam(c)
+	if err != nil {
+		return err
+	}
+	stream, ok := h.ys.Stream(id)
+	if !ok {
+		return apiError(http.StatusNotFound, "stream_not_found", fmt.Sprintf("stream %d not found", id))
+	}
+	return c.JSON(http.StatusOK, stream)
+}
+
+func (h *HTTPService) postStream(c echo.Context) error {
+	stream, err := decodeStream(c)
+	if err != nil {
+		return err
+	}
+	switch err := h.ys.CreateStream(stream); err {
+	case nil:
+	case ErrStreamExists:
+		return apiError(http.StatusConflict, "stream_exists", fmt.Sprintf("stream %d already exists", stream.ID))
+	default:
+		return validationError(err)
+	}
+	c.Response().Header().Set(echo.HeaderLocation, fmt.Sprintf("%sstreams/%d", apiPrefix, stream.ID))
+	return c.JSON(http.StatusCreated, stream)
+}
+
+// putStream replaces definition of existing stream. Id of body may be
+// omitted, otherwise it must match id of path
+func (h *HTTPService) putStream(c echo.Context) error {
+	id, err := streamIDParam(c)
+	if err != nil {
+		return err
+	}
+	stream, err := decodeStream(c)
+	if err != nil {
+		return err
+	}
+	if stream.ID == 0 {
+		stream.ID = id
+	}
+	if stream.ID != id {
+		return apiError(http.StatusUnprocessableEntity, "validation_failed", "stream is invalid",
+			FieldError{Field: "id", Message: "must match id in path"})
+	}
+	if err := ValidateStream(stream); err != nil {
+		return validationError(err)
+	}
+	if _, ok := h.ys.Stream(id); !ok {
+		return apiError(http.StatusNotFound, "stream_not_found", fmt.Sprintf("stream %d not found", id))
+	}
+	if err := h.ys.ApplyStream(stream, true, OriginAPI); err != nil {
+		return validationError(err)
+	}
+	return c.JSON(http.StatusOK, stream)
+}
+
+func (h *HTTPService) deleteStream(c echo.Context) error {
+	id, err := streamIDParam(c)
+	if err != nil {
+		return err
+	}
+	if !h.ys.RemoveStream(id) {
+		return apiError(http.StatusNotFound, "stream_not_found", fmt.Sprintf("stream %d not found", id))
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,265 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/subtle"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+// ScopeRead, ScopeControl and ScopeAdmin are scopes of API tokens. Every
+// scope includes the ones before it
+const (
+	ScopeRead    = "read"
+	ScopeControl = "control"
+	ScopeAdmin   = "admin"
+)
+
+// signatureSkew is how far timestamp of signed request may drift from now
+const signatureSkew = 5 * time.Minute
+
+// queryTokenPaths are routes that accept token in access_token query
+// parameter, players can't set headers for playlists and guides
+var queryTokenPaths = map[string]bool{
+	"/channels.m3u": true,
+	"/guide.xml":    true,
+}
+
+var scopeLevels = map[string]int{
+	ScopeRead:    1,
+	ScopeControl: 2,
+	ScopeAdmin:   3,
+}
+
+// AuditEntry describes request recorded in audit log
+type AuditEntry struct {
+	Time       time.Time `json:"time"`
+	RemoteAddr string    `json:"remote_addr"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Principal  string    `json:"principal,omitempty"`
+	Scope      string    `json:"scope"`
+	Status     int       `json:"status"`
+	Reason     string    `json:"reason,omitempty"`
+}
+
+// AuditLog appends entries to file as JSON lines
+type AuditLog struct {
+	sync.Mutex
+	file *os.File
+}
+
+// NewAuditLog opens audit log at path for appending
+func NewAuditLog(path string) (*AuditLog, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &AuditLog{file: file}, nil
+}
+
+// Write appends entry to log
+func (a *AuditLog) Write(entry AuditEntry) error {
+	body, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	a.Lock()
+	defer a.Unlock()
+	_, err = a.file.Write(append(body, '\n'))
+	return err
+}
+
+// replayCache remembers signatures of accepted requests until their
+// timestamp falls out of allowed skew, so signed request is accepted once
+type replayCache struct {
+	sync.Mutex
+	seen map[string]time.Time
+}
+
+func newReplayCache() *replayCache {
+	return &replayCache{seen: make(map[string]time.Time)}
+}
+
+// add returns false if signature was seen already. Expired signatures are pruned
+func (r *replayCache) add(signature string, expires time.Time) bool {
+	r.Lock()
+	defer r.Unlock()
+	now := time.Now()
+	for s, t := range r.seen {
+		if now.After(t) {
+			delete(r.seen, s)
+		}
+	}
+	if _, ok := r.seen[signature]; ok {
+		return false
+	}
+	r.seen[signature] = expires
+	return true
+}
+
+// parseTokens parses comma separated token:scope pairs
+func parseTokens(value string) map[string]string {
+	tokens := make(map[string]string)
+	for _, pair := range splitList(value) {
+		i := strings.LastIndex(pair, ":")
+		if i <= 0 {
+			continue
+		}
+		token, scope := pair[:i], pair[i+1:]
+		if _, ok := scopeLevels[scope]; ok {
+			tokens[token] = scope
+		}
+	}
+	return tokens
+}
+
+// tokenScope returns scope of token, tokens are compared in constant time
+func tokenScope(tokens map[string]string, token string) (string, bool) {
+	for t, scope := range tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return scope, true
+		}
+	}
+	return "", false
+}
+
+// authEnabled returns true if tokens or signing secret are configured
+func (h *HTTPService) authEnabled() bool {
+	config := h.s.Config()
+	return config.APITokens != "" || config.APISigningSecret != ""
+}
+
+// require allows request if it carries bearer token with scope or valid
+// HMAC signature, signed requests have control scope. Denied requests and
+// requests that change state are written to audit log
+func (h *HTTPService) require(scope string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !h.authEnabled() {
+				return next(c)
+			}
+			principal, granted, err := h.authenticate(c)
+			if err == nil && scopeLevels[granted] < scopeLevels[scope] {
+				err = echo.NewHTTPError(http.StatusForbidden, "token scope "+granted+" does not allow "+scope)
+			}
+			if err != nil {
+				status := http.StatusInternalServerError
+				if he, ok := err.(*echo.HTTPError); ok {
+					status = he.Code
+					if status == http.StatusUnauthorized {
+						c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer realm="yourcast-streamer"`)
+					}
+				}
+				h.audit(c, principal, scope, status, err.Error())
+				return err
+			}
+			err = next(c)
+			if scope != ScopeRead {
+				status := c.Response().Status
+				if he, ok := err.(*echo.HTTPError); ok {
+					status = he.Code
+				} else if err != nil {
+					status = http.StatusInternalServerError
+				}
+				h.audit(c, principal, scope, status, "")
+			}
+			return err
+		}
+	}
+}
+
+// authenticate returns principal and scope of request
+func (h *HTTPService) authenticate(c echo.Context) (string, string, error) {
+	req := c.Request()
+	if signature := req.Header.Get("X-Yourcast-Signature"); signature != "" {
+		if err := h.verifySignature(req, signature); err != nil {
+			return "webui", "", err
+		}
+		return "webui", ScopeControl, nil
+	}
+	token := ""
+	if header := req.Header.Get(echo.HeaderAuthorization); strings.HasPrefix(header, "Bearer ") {
+		token = strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+	} else if req.Method == http.MethodGet && queryTokenPaths[req.URL.Path] {
+		token = c.QueryParam("access_token")
+	}
+	if token == "" {
+		return "", "", echo.NewHTTPError(http.StatusUnauthorized, "missing bearer token")
+	}
+	principal := "token:" + maskToken(token)
+	scope, ok := tokenScope(parseTokens(h.s.Config().APITokens), token)
+	if !ok {
+		return principal, "", echo.NewHTTPError(http.StatusUnauthorized, "invalid bearer token")
+	}
+	return principal, scope, nil
+}
+
+// verifySignature checks HMAC-SHA256 of timestamp, method, path and body,
+// joined with newlines. Every signature is accepted once. Body is restored
+// for handlers
+func (h *HTTPService) verifySignature(req *http.Request, signature string) error {
+	secret := h.s.Config().APISigningSecret
+	if secret == "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "signed requests are not accepted")
+	}
+	timestamp := req.Header.Get("X-Yourcast-Timestamp")
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid signature timestamp")
+	}
+	if skew := time.Since(time.Unix(unix, 0)); skew > signatureSkew || skew < -signatureSkew {
+		return echo.NewHTTPError(http.StatusUnauthorized, "signature timestamp is out of range")
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "can't read body")
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	message := strings.Join([]string{timestamp, req.Method, req.URL.RequestURI(), string(body)}, "\n")
+	expected := "sha256=" + sign(secret, []byte(message))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid signature")
+	}
+	if !h.signatures.add(signature, time.Unix(unix, 0).Add(signatureSkew)) {
+		return echo.NewHTTPError(http.StatusUnauthorized, "signature was used already")
+	}
+	return nil
+}
+
+func (h *HTTPService) audit(c echo.Context, principal, scope string, status int, reason string) {
+	if h.audits == nil {
+		return
+	}
+	req := c.Request()
+	err := h.audits.Write(AuditEntry{
+		Time:       time.Now(),
+		RemoteAddr: c.RealIP(),
+		Method:     req.Method,
+		Path:       req.URL.Path,
+		Principal:  principal,
+		Scope:      scope,
+		Status:     status,
+		Reason:     reason,
+	})
+	if err != nil {
+		h.logger.Errorf("Got error %s while writing audit log", err)
+	}
+}
+
+func maskToken(token string) string {
+	if len(token) <= 8 {
+		return strings.Repeat("*", len(token))
+	}
+	return token[:4] + "****"
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/maddevsio/yourcast-streamer/service/data"
+)
+
+// catalogSnapshotFile is a name of last known good Web UI catalog in root path
+const catalogSnapshotFile = "catalog.json"
+
+// loadStreams returns streams of Web UI merged with streams of channels file
+// and origins of them. Successfully fetched Web UI catalog is saved as
+// snapshot, snapshot is used when Web UI is unreachable
+func (ys *YoutubeStreamService) loadStreams() ([]data.Stream, map[int]string, error) {
+	remote, err := ys.getStreams()
+	if err != nil {
+		ys.logger.Errorf("Got error %s while getting streams from Web UI, using snapshot", err)
+		remote, err = ys.loadSnapshot()
+		if err != nil && ys.s.Config().ChannelsPath == "" {
+			return nil, nil, fmt.Errorf("web ui is unreachable and snapshot is not available, %v", err)
+		}
+		if err != nil {
+			ys.logger.Errorf("Got error %s while loading snapshot", err)
+		}
+	} else if err := ys.saveSnapshot(remote); err != nil {
+		ys.logger.Errorf("Got error %s while saving snapshot", err)
+	}
+	local, err := ys.loadChannelsFile()
+	if err != nil {
+		return nil, nil, err
+	}
+	streams, origins := ys.mergeStreams(remote, local)
+	return streams, origins, nil
+}
+
+// loadChannelsFile reads streams defined in channels file. Streams are
+// described in the same shape as Web UI returns them
+func (ys *YoutubeStreamService) loadChannelsFile() ([]data.Stream, error) {
+	path := ys.s.Config().ChannelsPath
+	if path == "" {
+		return nil, nil
+	}
+	streams, err := readStreams(path)
+	if err != nil {
+		return nil, fmt.Errorf("channels file %s: %v", path, err)
+	}
+	for _, s := range streams {
+		if s.ID <= 0 {
+			return nil, fmt.Errorf("channels file %s: stream %q has no id", path, s.Name)
+		}
+	}
+	return streams, nil
+}
+
+// mergeStreams merges Web UI streams with local ones and returns origin of
+// every stream. Local stream replaces Web UI stream with the same id
+func (ys *YoutubeStreamService) mergeStreams(remote, local []data.Stream) ([]data.Stream, map[int]string) {
+	byID := make(map[int]data.Stream)
+	origins := make(map[int]string)
+	for _, s := range remote {
+		byID[s.ID] = s
+		origins[s.ID] = OriginWebUI
+	}
+	for _, s := range local {
+		if _, ok := byID[s.ID]; ok {
+			ys.logger.Debugf("Stream %d is defined in channels file, overriding Web UI stream", s.ID)
+		}
+		byID[s.ID] = s
+		origins[s.ID] = OriginFile
+	}
+	streams := make([]data.Stream, 0, len(byID))
+	for _, s := range byID {
+		streams = append(streams, s)
+	}
+	sort.Slice(streams, func(i, j int) bool {
+		return streams[i].ID < streams[j].ID
+	})
+	return streams, origins
+}
+
+func (ys *YoutubeStreamService) snapshotPath() string {
+	return filepath.Join(ys.s.Config().RootPath, catalogSnapshotFile)
+}
+
+func (ys *YoutubeStreamService) loadSnapshot() ([]data.Stream, error) {
+	streams, err := readStreams(ys.snapshotPath())
+	if err != nil {
+		return nil, err
+	}
+	ys.logger.Infof("Loaded %d streams from snapshot", len(streams))
+	return streams, nil
+}
+
+func (ys *YoutubeStreamService) saveSnapshot(streams []data.Stream) error {
+	body, err := json.Marshal(streams)
+	if err != nil {
+		return err
+	}
+	path := ys.snapshotPath()
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, body, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func readStreams(path string) ([]data.Stream, error) {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var streams []data.Stream
+	if err := json.Unmarshal(body, &streams); err != nil {
+		return nil, err
+	}
+	return streams, nil
+}
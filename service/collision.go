@@ -0,0 +1,139 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/maddevsio/yourcast-streamer/service/data"
+)
+
+// maxConflicts limits number of conflicts kept for diagnostics
+const maxConflicts = 500
+
+// CollisionDeferred, CollisionReordered and CollisionAllowed are actions
+// taken when video collides with another channel
+const (
+	CollisionDeferred  = "deferred"
+	CollisionReordered = "reordered"
+	CollisionAllowed   = "allowed"
+)
+
+// Conflict describes video that was going to air on two channels within window
+type Conflict struct {
+	URL          string    `json:"url"`
+	Channel      int       `json:"channel"`
+	OtherChannel int       `json:"other_channel"`
+	Action       string    `json:"action"`
+	Time         time.Time `json:"time"`
+}
+
+type airing struct {
+	channel int
+	started time.Time
+}
+
+// CollisionGuard prevents the same video from playing on different
+// channels within window. Zero window disables guard
+type CollisionGuard struct {
+	sync.Mutex
+	window    time.Duration
+	airings   map[string]airing
+	conflicts []Conflict
+}
+
+// NewCollisionGuard creates guard with window
+func NewCollisionGuard(window time.Duration) *CollisionGuard {
+	return &CollisionGuard{
+		window:  window,
+		airings: make(map[string]airing),
+	}
+}
+
+// Claim registers start of url on channel. It returns false if url was
+// started on another channel within window, unless force is set
+func (g *CollisionGuard) Claim(channel int, url string, force bool) bool {
+	if g.window <= 0 {
+		return true
+	}
+	g.Lock()
+	defer g.Unlock()
+	g.prune()
+	if other, ok := g.collides(channel, url); ok {
+		if !force {
+			g.addConflict(url, channel, other, CollisionDeferred)
+			return false
+		}
+		g.addConflict(url, channel, other, CollisionAllowed)
+	}
+	g.airings[url] = airing{channel: channel, started: time.Now()}
+	return true
+}
+
+// Reorder moves links that collide with other channels to the end
+func (g *CollisionGuard) Reorder(channel int, links []data.StreamLink) []data.StreamLink {
+	if g.window <= 0 {
+		return links
+	}
+	g.Lock()
+	defer g.Unlock()
+	g.prune()
+	ordered := make([]data.StreamLink, 0, len(links))
+	var deferred []data.StreamLink
+	for _, link := range links {
+		if other, ok := g.collides(channel, link.URL); ok {
+			g.addConflict(link.URL, channel, other, CollisionReordered)
+			deferred = append(deferred, link)
+			continue
+		}
+		ordered = append(ordered, link)
+	}
+	return append(ordered, deferred...)
+}
+
+// Window returns collision window
+func (g *CollisionGuard) Window() time.Duration {
+	return g.window
+}
+
+// Conflicts returns copy of recent conflicts
+func (g *CollisionGuard) Conflicts() []Conflict {
+	g.Lock()
+	defer g.Unlock()
+	conflicts := make([]Conflict, len(g.conflicts))
+	copy(conflicts, g.conflicts)
+	return conflicts
+}
+
+func (g *CollisionGuard) collides(channel int, url string) (int, bool) {
+	a, ok := g.airings[url]
+	if !ok {
+		return 0, false
+	}
+	if time.Since(a.started) > g.window {
+		delete(g.airings, url)
+		return 0, false
+	}
+	return a.channel, a.channel != channel
+}
+
+// prune forgets airings started before window
+func (g *CollisionGuard) prune() {
+	for url, a := range g.airings {
+		if time.Since(a.started) > g.window {
+			delete(g.airings, url)
+		}
+	}
+}
+
+func (g *CollisionGuard) addConflict(url string, channel, other int, action string) {
+	g.conflicts = append(g.conflicts, Conflict{
+		URL:          url,
+		Channel:      channel,
+		OtherChannel: other,
+		Action:       action,
+		Time:         time.Now(),
+	})
+	if len(g.conflicts) > maxConflicts {
+		g.conflicts = g.conflicts[len(g.conflicts)-maxConflicts:]
+	}
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gen1us2k/log"
+	"github.com/maddevsio/yourcast-streamer/conf"
+)
+
+// configPollInterval is how often config file is checked for changes
+const configPollInterval = 5 * time.Second
+
+// ReloadReport describes result of config file reload
+type ReloadReport struct {
+	Time    time.Time     `json:"time"`
+	Path    string        `json:"path"`
+	Error   string        `json:"error,omitempty"`
+	Changes []conf.Change `json:"changes"`
+}
+
+// ConfigService reloads config file on SIGHUP or when file changes.
+// New config is validated before applying, only live settings are applied
+type ConfigService struct {
+	BaseService
+
+	s       *Streamer
+	mu      sync.Mutex
+	modTime time.Time
+	last    *ReloadReport
+
+	logger log.Logger
+}
+
+// Name returns name of service
+func (cs *ConfigService) Name() string {
+	return "config_watcher"
+}
+
+// Init initializes logger
+func (cs *ConfigService) Init(s *Streamer) error {
+	cs.s = s
+	cs.logger = log.NewLogger(cs.Name())
+	if path := s.Config().ConfigPath; path != "" {
+		if info, err := os.Stat(path); err == nil {
+			cs.modTime = info.ModTime()
+		}
+	}
+	return nil
+}
+
+// Run watches config file
+func (cs *ConfigService) Run() error {
+	path := cs.s.Config().ConfigPath
+	if path == "" {
+		cs.logger.Info("Config file is not set, hot reload is disabled")
+		return nil
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+	for !cs.IsNeedStop() {
+		select {
+		case <-hup:
+			cs.logger.Info("SIGHUP received, reloading config")
+			cs.Reload()
+		case <-ticker.C:
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			cs.mu.Lock()
+			changed := !info.ModTime().Equal(cs.modTime)
+			cs.modTime = info.ModTime()
+			cs.mu.Unlock()
+			if changed {
+				cs.logger.Info("Config file changed, reloading config")
+				cs.Reload()
+			}
+		}
+	}
+	return nil
+}
+
+// Reload reads config file, validates it and applies live settings
+func (cs *ConfigService) Reload() ReloadReport {
+	current := cs.s.Config()
+	report := ReloadReport{Time: time.Now(), Path: current.ConfigPath}
+	next, err := conf.Load(current.ConfigPath, current)
+	if err == nil {
+		err = next.Validate()
+	}
+	if err != nil {
+		cs.logger.Errorf("Config %s is not applied, %v", current.ConfigPath, err)
+		report.Error = err.Error()
+		cs.setLast(report)
+		return report
+	}
+	report.Changes = conf.Diff(current, next)
+	for _, change := range report.Changes {
+		if change.Live {
+			cs.logger.Infof("Setting %s changed from %v to %v", change.Name, change.Old, change.New)
+		} else {
+			cs.logger.Infof("Setting %s changed from %v to %v, restart required to apply", change.Name, change.Old, change.New)
+		}
+	}
+	applied := conf.ApplyLive(current, next)
+	if applied.LogLevel != current.LogLevel {
+		log.SetLevel(log.MustParseLevel(applied.LogLevel))
+	}
+	cs.s.SetConfig(applied)
+	cs.setLast(report)
+	return report
+}
+
+func (cs *ConfigService) setLast(report ReloadReport) {
+	cs.mu.Lock()
+	cs.last = &report
+	cs.mu.Unlock()
+}
+
+// LastReload returns report of last reload or nil if config was not reloaded
+func (cs *ConfigService) LastReload() *ReloadReport {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.last
+}
@@ -0,0 +1,14 @@
+package data
+
+// ScheduleBlock assigns content to time slot of stream. Block repeats on
+// weekdays or airs on specific dates, it airs every day if neither is set.
+// Start is a wall-clock time in timezone of stream, e.g. 20:00, weekdays
+// are names like mon or monday, dates are in 2006-01-02 format
+type ScheduleBlock struct {
+	Title    string   `json:"title"`
+	Start    string   `json:"start"`
+	Duration int      `json:"duration"`
+	Weekdays []string `json:"weekdays"`
+	Dates    []string `json:"dates"`
+	Content  Stream   `json:"content"`
+}
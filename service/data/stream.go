@@ -9,21 +9,55 @@ type StreamLink struct {
 
 // Stream struct for parsing WebUIAPi responses
 type Stream struct {
-	Name            string       `json:"name"`
-	ID              int          `json:"id"`
-	Slug            string       `json:"slug"`
-	Links           []StreamLink `json:"links"`
-	Keywords        string       `json:"keywords"`
-	Channels        string       `json:"channels"`
-	UpdateFrequency int          `json:"update_frequency"`
-	VideoLength     int          `json:"video_length"`
-	IsNews          bool         `json:"is_news"`
+	Name            string             `json:"name"`
+	ID              int                `json:"id"`
+	Slug            string             `json:"slug"`
+	Logo            string             `json:"logo"`
+	Group           string             `json:"group"`
+	Links           []StreamLink       `json:"links"`
+	Keywords        string             `json:"keywords"`
+	Channels        string             `json:"channels"`
+	Playlists       string             `json:"playlists"`
+	Feeds           string             `json:"feeds"`
+	UseFeeds        bool               `json:"use_feeds"`
+	Shuffle         bool               `json:"shuffle"`
+	MaxResults      int                `json:"max_results"`
+	LookbackHours   int                `json:"lookback_hours"`
+	MinDuration     int                `json:"min_duration"`
+	MaxDuration     int                `json:"max_duration"`
+	ExcludeKeywords string             `json:"exclude_keywords"`
+	Language        string             `json:"relevance_language"`
+	RegionCode      string             `json:"region_code"`
+	SafeSearch      string             `json:"safe_search"`
+	Definition      string             `json:"definition"`
+	Caption         string             `json:"caption"`
+	License         string             `json:"license"`
+	ExcludeLive     bool               `json:"exclude_live"`
+	IncludeLive     bool               `json:"include_live"`
+	Ordering        string             `json:"ordering"`
+	Weights         map[string]float64 `json:"weights"`
+	NoRepeatMinutes int                `json:"no_repeat_minutes"`
+	NoRepeatCount   int                `json:"no_repeat_count"`
+	UpdateFrequency int                `json:"update_frequency"`
+	VideoLength     int                `json:"video_length"`
+	IsNews          bool               `json:"is_news"`
+	Directory       string             `json:"directory"`
+	Recursive       bool               `json:"recursive"`
+	Patterns        string             `json:"patterns"`
+	Timezone        string             `json:"timezone"`
+	Schedule        []ScheduleBlock    `json:"schedule"`
+	Filler          []StreamLink       `json:"filler"`
 	IsAuto          bool
 }
 
 // IsAutoStream returns true if stream is for botService
 func (s *Stream) IsAutoStream() bool {
-	return s.Keywords != "" || s.Channels != ""
+	return s.Keywords != "" || s.Channels != "" || s.Playlists != "" || s.Feeds != ""
+}
+
+// IsLibraryStream returns true if stream is built from local directory
+func (s *Stream) IsLibraryStream() bool {
+	return s.Directory != ""
 }
 
 // ToStreamItem converts Stream struct into StreamItem
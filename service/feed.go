@@ -0,0 +1,42 @@
+package service
+
+import (
+	"time"
+
+	"google.golang.org/api/youtube/v3"
+
+	"github.com/maddevsio/yourcast-streamer/service/data"
+)
+
+// getFeedContent returns candidates from channel uploads feed. Feed results
+// pass the same filters as search results
+func (ys *YoutubeStreamService) getFeedContent(channel string, as data.Stream) ([]candidate, error) {
+	entries, err := ys.feeds.Channel(channel)
+	if err != nil {
+		return nil, err
+	}
+	lookback := time.Duration(as.LookbackHours) * time.Hour
+	if lookback == 0 && as.IsNews {
+		lookback = 24 * time.Hour
+	}
+	since := time.Now().Add(-lookback)
+	var results []*youtube.SearchResult
+	for _, entry := range entries {
+		if lookback > 0 && entry.Published.Before(since) {
+			continue
+		}
+		results = append(results, &youtube.SearchResult{
+			Id: &youtube.ResourceId{
+				Kind:    "youtube#video",
+				VideoId: entry.VideoID,
+			},
+			Snippet: &youtube.SearchResultSnippet{
+				ChannelId:   entry.ChannelID,
+				Title:       entry.Title,
+				PublishedAt: entry.Published.Format(time.RFC3339),
+			},
+		})
+	}
+	results = ys.filterByDuration(as, filterResults(as, results))
+	return toCandidates(as, channel, results), nil
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"strings"
+	"time"
+
+	"google.golang.org/api/youtube/v3"
+
+	"github.com/maddevsio/yourcast-streamer/bot"
+	"github.com/maddevsio/yourcast-streamer/service/data"
+)
+
+// searchFilter converts filter rules of autostream into search parameters
+func searchFilter(as data.Stream) bot.SearchFilter {
+	return bot.SearchFilter{
+		Lookback:          time.Duration(as.LookbackHours) * time.Hour,
+		Duration:          durationBucket(as.MinDuration, as.MaxDuration),
+		RelevanceLanguage: as.Language,
+		RegionCode:        as.RegionCode,
+		SafeSearch:        as.SafeSearch,
+		Definition:        as.Definition,
+		Caption:           as.Caption,
+		License:           as.License,
+	}
+}
+
+// durationBucket returns videoDuration search parameter that covers
+// min and max duration in seconds. Exact bounds are checked by filterByDuration
+func durationBucket(min, max int) string {
+	switch {
+	case max > 0 && max <= 4*60:
+		return "short"
+	case min >= 20*60:
+		return "long"
+	case min >= 4*60 && max > 0 && max <= 20*60:
+		return "medium"
+	}
+	return ""
+}
+
+// filterResults drops videos excluded by keywords or by broadcast state
+func filterResults(as data.Stream, results []*youtube.SearchResult) []*youtube.SearchResult {
+	var excluded []string
+	for _, keyword := range strings.Split(as.ExcludeKeywords, ",") {
+		if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" {
+			excluded = append(excluded, keyword)
+		}
+	}
+	var filtered []*youtube.SearchResult
+	for _, item := range results {
+		if item.Id == nil || item.Id.Kind != "youtube#video" {
+			continue
+		}
+		if item.Snippet != nil {
+			if as.ExcludeLive && item.Snippet.LiveBroadcastContent != "" && item.Snippet.LiveBroadcastContent != "none" {
+				continue
+			}
+			if containsAny(item.Snippet.Title+" "+item.Snippet.Description, excluded) {
+				continue
+			}
+		}
+		filtered = append(filtered, item)
+	}
+	return filtered
+}
+
+func containsAny(text string, keywords []string) bool {
+	text = strings.ToLower(text)
+	for _, keyword := range keywords {
+		if strings.Contains(text, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterByDuration drops videos which duration is out of bounds. Nothing
+// is returned if durations can't be fetched, next refresh retries
+func (ys *YoutubeStreamService) filterByDuration(as data.Stream, results []*youtube.SearchResult) []*youtube.SearchResult {
+	if (as.MinDuration <= 0 && as.MaxDuration <= 0) || len(results) == 0 {
+		return results
+	}
+	var ids []string
+	for _, item := range results {
+		ids = append(ids, item.Id.VideoId)
+	}
+	videos, err := ys.yc.Videos(ids)
+	if err != nil {
+		ys.logger.Errorf("Error while requesting video details, %v", err)
+		return nil
+	}
+	ys.indexVideos(videos)
+	durations := make(map[string]time.Duration)
+	for _, video := range videos {
+		if video.ContentDetails == nil {
+			continue
+		}
+		if d, err := bot.ParseDuration(video.ContentDetails.Duration); err == nil {
+			durations[video.Id] = d
+		}
+	}
+	min := time.Duration(as.MinDuration) * time.Second
+	max := time.Duration(as.MaxDuration) * time.Second
+	var filtered []*youtube.SearchResult
+	for _, item := range results {
+		d, ok := durations[item.Id.VideoId]
+		if !ok || d < min || (max > 0 && d > max) {
+			continue
+		}
+		filtered = append(filtered, item)
+	}
+	return filtered
+}
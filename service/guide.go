@@ -0,0 +1,174 @@
+package service
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// xmltvTimeFormat is time format of XMLTV programme start and stop
+const xmltvTimeFormat = "20060102150405 -0700"
+
+// Programme is entry of channel guide
+type Programme struct {
+	Title string
+	Link  string
+	Start time.Time
+	Stop  time.Time
+}
+
+type xmltvGuide struct {
+	XMLName    xml.Name         `xml:"tv"`
+	Generator  string           `xml:"generator-info-name,attr"`
+	Channels   []xmltvChannel   `xml:"channel"`
+	Programmes []xmltvProgramme `xml:"programme"`
+}
+
+type xmltvChannel struct {
+	ID          string     `xml:"id,attr"`
+	DisplayName string     `xml:"display-name"`
+	Icon        *xmltvIcon `xml:"icon,omitempty"`
+}
+
+type xmltvIcon struct {
+	Src string `xml:"src,attr"`
+}
+
+type xmltvProgramme struct {
+	Start   string `xml:"start,attr"`
+	Stop    string `xml:"stop,attr,omitempty"`
+	Channel string `xml:"channel,attr"`
+	Title   string `xml:"title"`
+}
+
+// programmes returns upcoming programme of stream until to. It's projected
+// from current entry through queue using durations of media index, queue
+// entries are cut by scheduled blocks. Projection ends at first entry with
+// unknown duration
+func (ys *YoutubeStreamService) programmes(id int, to time.Time) []Programme {
+	as, ok := ys.definition(id)
+	if !ok {
+		return nil
+	}
+	now := time.Now()
+	ys.ss.RLock()
+	var queue []string
+	if links := ys.ss.Items[id].Links; links != nil {
+		for e := links.Front(); e != nil; e = e.Next() {
+			queue = append(queue, fmt.Sprintf("%v", e.Value))
+		}
+	}
+	ys.ss.RUnlock()
+
+	cursor, idx := now, 0
+	if state, ok := ys.state.Current(id); ok {
+		for i, link := range queue {
+			if link == state.Current {
+				cursor, idx = state.StartedAt, i
+				break
+			}
+		}
+	}
+	scheduled := airings(as, cursor, to)
+	var result []Programme
+	known := len(queue) > 0
+	for cursor.Before(to) {
+		if len(scheduled) > 0 && !cursor.Before(scheduled[0].Start) {
+			airing := scheduled[0]
+			scheduled = scheduled[1:]
+			if airing.End.After(cursor) {
+				result = append(result, Programme{Title: airing.Title(), Start: airing.Start, Stop: airing.End})
+				cursor = airing.End
+			}
+			continue
+		}
+		if !known {
+			for _, airing := range scheduled {
+				result = append(result, Programme{Title: airing.Title(), Start: airing.Start, Stop: airing.End})
+			}
+			break
+		}
+		link := queue[idx%len(queue)]
+		info, ok := ys.media.Get(link)
+		if !ok || info.Duration <= 0 {
+			result = append(result, Programme{Title: link, Link: link, Start: cursor})
+			known = false
+			continue
+		}
+		stop := cursor.Add(info.Duration)
+		if len(scheduled) > 0 && scheduled[0].Start.Before(stop) {
+			stop = scheduled[0].Start
+		}
+		result = append(result, Programme{Title: info.Title, Link: link, Start: cursor, Stop: stop})
+		cursor = stop
+		idx++
+	}
+	return result
+}
+
+// XMLTV returns XMLTV guide of every stream for upcoming period
+func (ys *YoutubeStreamService) XMLTV(period time.Duration) ([]byte, error) {
+	guide := xmltvGuide{Generator: "yourcast-streamer"}
+	to := time.Now().Add(period)
+	for _, id := range ys.definitionIDs() {
+		as, ok := ys.definition(id)
+		if !ok {
+			continue
+		}
+		channel := xmltvChannel{ID: as.Slug, DisplayName: as.Name}
+		if as.Logo != "" {
+			channel.Icon = &xmltvIcon{Src: as.Logo}
+		}
+		guide.Channels = append(guide.Channels, channel)
+		for _, p := range ys.programmes(id, to) {
+			programme := xmltvProgramme{
+				Start:   p.Start.Format(xmltvTimeFormat),
+				Channel: as.Slug,
+				Title:   p.Title,
+			}
+			if !p.Stop.IsZero() {
+				programme.Stop = p.Stop.Format(xmltvTimeFormat)
+			}
+			guide.Programmes = append(guide.Programmes, programme)
+		}
+	}
+	body, err := xml.MarshalIndent(guide, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+// M3U returns playlist of every stream pointing at its HLS url
+func (ys *YoutubeStreamService) M3U() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("#EXTM3U\n")
+	hlsURL := strings.TrimSuffix(ys.s.Config().HLSURL, "/")
+	for _, id := range ys.definitionIDs() {
+		as, ok := ys.definition(id)
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(&buf, `#EXTINF:-1 tvg-id="%s" tvg-name="%s"`, m3uAttr(as.Slug), m3uAttr(as.Name))
+		if as.Logo != "" {
+			fmt.Fprintf(&buf, ` tvg-logo="%s"`, m3uAttr(as.Logo))
+		}
+		if as.Group != "" {
+			fmt.Fprintf(&buf, ` group-title="%s"`, m3uAttr(as.Group))
+		}
+		fmt.Fprintf(&buf, ",%s\n%s/%s.m3u8\n", m3uTitle(as.Name), hlsURL, as.Slug)
+	}
+	return buf.Bytes()
+}
+
+// m3uAttr removes quotes and line breaks that can't be escaped in M3U attributes
+func m3uAttr(value string) string {
+	return strings.Replace(m3uTitle(value), `"`, "'", -1)
+}
+
+// m3uTitle removes line breaks from title of M3U entry
+func m3uTitle(value string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/maddevsio/yourcast-streamer/service/data"
+)
+
+// maxHistoryRecords limits number of plays stored per channel
+const maxHistoryRecords = 1000
+
+// minRefreshLinks is a minimal size of refreshed autostream. If there are
+// not enough videos that were not played recently, least recently played
+// ones are used
+const minRefreshLinks = 5
+
+// PlayRecord stores when video was played on channel
+type PlayRecord struct {
+	URL      string    `json:"url"`
+	PlayedAt time.Time `json:"played_at"`
+}
+
+// PlayHistory stores plays of every channel and persists them on disk
+type PlayHistory struct {
+	sync.Mutex
+	path     string
+	Channels map[int][]PlayRecord `json:"channels"`
+}
+
+// NewPlayHistory loads history from path. Missing file means empty history
+func NewPlayHistory(path string) (*PlayHistory, error) {
+	h := &PlayHistory{path: path, Channels: make(map[int][]PlayRecord)}
+	body, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(body, h); err != nil {
+		return nil, err
+	}
+	if h.Channels == nil {
+		h.Channels = make(map[int][]PlayRecord)
+	}
+	return h, nil
+}
+
+// Record adds play of url on channel and saves history
+func (h *PlayHistory) Record(channel int, url string) error {
+	h.Lock()
+	defer h.Unlock()
+	records := append(h.Channels[channel], PlayRecord{URL: url, PlayedAt: time.Now()})
+	if len(records) > maxHistoryRecords {
+		records = records[len(records)-maxHistoryRecords:]
+	}
+	h.Channels[channel] = records
+	return h.save()
+}
+
+// Recent returns last play time of urls played on channel within window
+// or within last count plays. Zero window and count means nothing is recent
+func (h *PlayHistory) Recent(channel int, window time.Duration, count int) map[string]time.Time {
+	h.Lock()
+	defer h.Unlock()
+	recent := make(map[string]time.Time)
+	records := h.Channels[channel]
+	since := time.Now().Add(-window)
+	for i := len(records) - 1; i >= 0; i-- {
+		r := records[i]
+		inWindow := window > 0 && r.PlayedAt.After(since)
+		inCount := len(records)-i <= count
+		if !inWindow && !inCount {
+			continue
+		}
+		if _, ok := recent[r.URL]; !ok {
+			recent[r.URL] = r.PlayedAt
+		}
+	}
+	return recent
+}
+
+// Played returns true if url was played on channel
+func (h *PlayHistory) Played(channel int, url string) bool {
+	h.Lock()
+	defer h.Unlock()
+	for _, r := range h.Channels[channel] {
+		if r.URL == url {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *PlayHistory) save() error {
+	body, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	tmp := h.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, body, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, h.path)
+}
+
+// avoidRepeats moves videos played recently on channel out of links.
+// If too few videos are left, least recently played ones are added back
+func (ys *YoutubeStreamService) avoidRepeats(as data.Stream, links []data.StreamLink) []data.StreamLink {
+	if as.NoRepeatMinutes <= 0 && as.NoRepeatCount <= 0 {
+		return links
+	}
+	recent := ys.history.Recent(as.ID, time.Duration(as.NoRepeatMinutes)*time.Minute, as.NoRepeatCount)
+	var fresh, played []data.StreamLink
+	for _, link := range links {
+		if _, ok := recent[link.URL]; ok {
+			played = append(played, link)
+			continue
+		}
+		fresh = append(fresh, link)
+	}
+	if len(fresh) >= minRefreshLinks || len(played) == 0 {
+		return fresh
+	}
+	sort.SliceStable(played, func(i, j int) bool {
+		return recent[played[i].URL].Before(recent[played[j].URL])
+	})
+	for _, link := range played {
+		if len(fresh) >= minRefreshLinks {
+			break
+		}
+		fresh = append(fresh, link)
+	}
+	ys.logger.Infof("Not enough new videos for %s, reusing %d played ones", as.Name, len(fresh)-len(links)+len(played))
+	return fresh
+}
@@ -2,20 +2,38 @@ package service
 
 import (
 	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gen1us2k/log"
 	"github.com/labstack/echo"
 	"github.com/maddevsio/yourcast-streamer/service/data"
+	"golang.org/x/net/websocket"
 )
 
+// defaultEPGHours and maxEPGHours limit period of EPG grid
+const (
+	defaultEPGHours = 24
+	maxEPGHours     = 7 * 24
+)
+
+// eventsKeepAlive is how often comment is sent to idle event stream
+const eventsKeepAlive = 30 * time.Second
+
 // HTTPService implements a simple api for streamer
 type HTTPService struct {
 	BaseService
 
-	s      *Streamer
-	e      *echo.Echo
-	ys     *YoutubeStreamService
-	logger log.Logger
+	s          *Streamer
+	e          *echo.Echo
+	ys         *YoutubeStreamService
+	ws         *WebSubService
+	audits     *AuditLog
+	signatures *replayCache
+	logger     log.Logger
 }
 
 // Name returns name of service
@@ -28,9 +46,48 @@ func (h *HTTPService) Init(s *Streamer) error {
 	h.s = s
 	h.logger = log.NewLogger(h.Name())
 	h.e = echo.New()
+	h.e.HTTPErrorHandler = h.handleError
 	h.ys = h.s.YoutubeStreamService()
-	h.e.POST("/stream/add", h.addStream)
-	h.e.POST("/stream/update", h.updateStream)
+	h.ws = h.s.WebSubService()
+	h.signatures = newReplayCache()
+	if path := h.s.Config().AuditLogPath; path != "" {
+		audits, err := NewAuditLog(path)
+		if err != nil {
+			return err
+		}
+		h.audits = audits
+	}
+	if !h.authEnabled() {
+		h.logger.Info("API tokens and signing secret are not set, control API is not protected")
+	}
+	h.e.POST("/stream/add", h.addStream, h.require(ScopeControl))
+	h.e.POST("/stream/update", h.updateStream, h.require(ScopeControl))
+	h.e.GET("/stream/quarantine", h.quarantine, h.require(ScopeRead))
+	h.e.GET("/quota", h.quota, h.require(ScopeRead))
+	h.e.GET("/keys", h.keys, h.require(ScopeAdmin))
+	h.e.GET("/collisions", h.collisions, h.require(ScopeRead))
+	h.e.GET("/websub", h.websubVerify)
+	h.e.POST("/websub", h.websubNotify)
+	h.e.GET("/websub/subscriptions", h.websubSubscriptions, h.require(ScopeRead))
+	h.e.GET("/config/reload", h.lastConfigReload, h.require(ScopeRead))
+	h.e.GET("/reconcile", h.lastReconcile, h.require(ScopeRead))
+	h.e.GET("/epg", h.epg, h.require(ScopeRead))
+	h.e.GET("/channels.m3u", h.m3u, h.require(ScopeRead))
+	h.e.GET("/guide.xml", h.xmltv, h.require(ScopeRead))
+	h.e.GET("/webhooks", h.webhooks, h.require(ScopeRead))
+	h.e.GET("/now-playing", h.nowPlaying, h.require(ScopeRead))
+	h.e.GET("/now-playing/events", h.nowPlayingEvents, h.require(ScopeRead))
+	h.e.GET("/now-playing/ws", h.nowPlayingWebSocket, h.require(ScopeRead))
+	h.e.POST("/reconcile", h.reconcile, h.require(ScopeControl))
+	h.e.POST("/config/reload", h.reloadConfig, h.require(ScopeAdmin))
+	h.e.POST("/keys/add", h.addKey, h.require(ScopeAdmin))
+	h.e.POST("/keys/remove", h.removeKey, h.require(ScopeAdmin))
+
+	h.e.GET("/v1/streams", h.listStreams, h.require(ScopeRead))
+	h.e.GET("/v1/streams/:id", h.getStream, h.require(ScopeRead))
+	h.e.POST("/v1/streams", h.postStream, h.require(ScopeControl))
+	h.e.PUT("/v1/streams/:id", h.putStream, h.require(ScopeControl))
+	h.e.DELETE("/v1/streams/:id", h.deleteStream, h.require(ScopeControl))
 	return nil
 }
 
@@ -40,40 +97,285 @@ func (h *HTTPService) Run() error {
 	return nil
 }
 
+// addStream applies stream passed as JSON in form field data.
+// Deprecated in favour of POST /v1/streams
 func (h *HTTPService) addStream(c echo.Context) error {
-	streamData := c.FormValue("data")
-	var stream data.Stream
-	err := json.Unmarshal([]byte(streamData), &stream)
+	stream, err := h.formStream(c)
 	if err != nil {
-		h.logger.Errorf("caught error on json unmarshaling: %s", err)
 		return err
 	}
-	if !stream.IsAutoStream() {
-		h.logger.Infof("adding a new stream: %s", stream.Name)
-		h.ys.AddStream(stream.ToStreamItem(), true)
-	} else {
-		h.logger.Infof("adding autostream: %s", stream.Name)
-		h.ys.AddAutoStream(stream)
-	}
-	return nil
+	return h.applyFormStream(stream, false)
 }
 
+// updateStream applies stream passed as JSON in form field data.
+// Deprecated in favour of PUT /v1/streams/:id
 func (h *HTTPService) updateStream(c echo.Context) error {
+	stream, err := h.formStream(c)
+	if err != nil {
+		return err
+	}
+	return h.applyFormStream(stream, true)
+}
+
+// formStream reads stream from form field data. Parsing is lenient,
+// unknown fields are ignored
+func (h *HTTPService) formStream(c echo.Context) (data.Stream, error) {
+	c.Response().Header().Set("Deprecation", "true")
 	streamData := c.FormValue("data")
 	h.logger.Info(streamData)
 	var stream data.Stream
 	err := json.Unmarshal([]byte(streamData), &stream)
 	if err != nil {
 		h.logger.Errorf("caught error on json unmarshaling: %s", err)
+		return stream, echo.NewHTTPError(http.StatusBadRequest, "data must be JSON object of stream")
+	}
+	return stream, nil
+}
+
+// applyFormStream applies stream of form endpoints, invalid streams are
+// rejected. Form endpoints are used by Web UI, so streams stay reconciled
+func (h *HTTPService) applyFormStream(stream data.Stream, update bool) error {
+	if err := h.ys.ApplyStream(stream, update, OriginWebUI); err != nil {
+		h.logger.Errorf("Got error %s while validating stream %s", err, stream.Name)
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	}
+	return nil
+}
+
+func (h *HTTPService) quarantine(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.ys.Quarantine())
+}
+
+func (h *HTTPService) quota(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.ys.QuotaUsage())
+}
+
+func (h *HTTPService) keys(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.ys.APIKeys())
+}
+
+func (h *HTTPService) addKey(c echo.Context) error {
+	if !h.ys.AddAPIKey(c.FormValue("key")) {
+		return echo.NewHTTPError(http.StatusBadRequest, "key is empty or already exists")
+	}
+	h.logger.Info("youtube api key added")
+	return c.JSON(http.StatusOK, h.ys.APIKeys())
+}
+
+func (h *HTTPService) removeKey(c echo.Context) error {
+	if !h.ys.RemoveAPIKey(c.FormValue("key")) {
+		return echo.NewHTTPError(http.StatusNotFound, "key not found")
+	}
+	h.logger.Info("youtube api key removed")
+	return c.JSON(http.StatusOK, h.ys.APIKeys())
+}
+
+func (h *HTTPService) collisions(c echo.Context) error {
+	window, conflicts := h.ys.Collisions()
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"window":    int(window / time.Second),
+		"conflicts": conflicts,
+	})
+}
+
+func (h *HTTPService) websubVerify(c echo.Context) error {
+	lease, _ := strconv.Atoi(c.QueryParam("hub.lease_seconds"))
+	challenge, ok := h.ws.Verify(
+		c.QueryParam("hub.mode"),
+		c.QueryParam("hub.topic"),
+		c.QueryParam("hub.challenge"),
+		lease,
+	)
+	if !ok {
+		return c.NoContent(http.StatusNotFound)
+	}
+	return c.String(http.StatusOK, challenge)
+}
+
+func (h *HTTPService) websubNotify(c echo.Context) error {
+	body, err := ioutil.ReadAll(c.Request().Body)
+	if err != nil {
+		return err
+	}
+	h.ws.Notify(body, c.Request().Header.Get("X-Hub-Signature"))
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *HTTPService) websubSubscriptions(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.ws.Subscriptions())
+}
+
+func (h *HTTPService) lastConfigReload(c echo.Context) error {
+	report := h.s.ConfigService().LastReload()
+	if report == nil {
+		return c.NoContent(http.StatusNoContent)
+	}
+	return c.JSON(http.StatusOK, report)
+}
+
+func (h *HTTPService) reloadConfig(c echo.Context) error {
+	if h.s.Config().ConfigPath == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "config file is not set")
+	}
+	report := h.s.ConfigService().Reload()
+	if report.Error != "" {
+		return c.JSON(http.StatusUnprocessableEntity, report)
+	}
+	return c.JSON(http.StatusOK, report)
+}
+
+func (h *HTTPService) lastReconcile(c echo.Context) error {
+	report := h.ys.LastReconcile()
+	if report == nil {
+		return c.NoContent(http.StatusNoContent)
+	}
+	return c.JSON(http.StatusOK, report)
+}
+
+func (h *HTTPService) reconcile(c echo.Context) error {
+	report := h.ys.Reconcile()
+	if report.Error != "" {
+		return c.JSON(http.StatusBadGateway, report)
+	}
+	return c.JSON(http.StatusOK, report)
+}
+
+func (h *HTTPService) epg(c echo.Context) error {
+	from := time.Now()
+	if value := c.QueryParam("from"); value != "" {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "from must be in RFC3339 format")
+		}
+		from = t
+	}
+	hours := defaultEPGHours
+	if value := c.QueryParam("hours"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > maxEPGHours {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("hours must be between 1 and %d", maxEPGHours))
+		}
+		hours = n
+	}
+	to := from.Add(time.Duration(hours) * time.Hour)
+	return c.JSON(http.StatusOK, h.ys.EPG(from, to))
+}
+
+func (h *HTTPService) m3u(c echo.Context) error {
+	return c.Blob(http.StatusOK, "audio/x-mpegurl", h.ys.M3U())
+}
+
+func (h *HTTPService) xmltv(c echo.Context) error {
+	hours := defaultEPGHours
+	if value := c.QueryParam("hours"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > maxEPGHours {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("hours must be between 1 and %d", maxEPGHours))
+		}
+		hours = n
+	}
+	body, err := h.ys.XMLTV(time.Duration(hours) * time.Hour)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.XMLBlob(http.StatusOK, body)
+}
+
+func (h *HTTPService) nowPlaying(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.ys.NowPlaying().Current())
+}
+
+// nowPlayingEvents streams now-playing updates as Server-Sent Events.
+// Updates are filtered by channel slug if it's set
+func (h *HTTPService) nowPlayingEvents(c echo.Context) error {
+	slug := c.QueryParam("channel")
+	hub := h.ys.NowPlaying()
+	updates, cancel := hub.Subscribe()
+	defer cancel()
+
+	w := c.Response()
+	w.Header().Set(echo.HeaderContentType, "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	send := func(np NowPlaying) error {
+		if slug != "" && np.Slug != slug {
+			return nil
+		}
+		body, err := json.Marshal(np)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "event: now-playing\ndata: %s\n\n", body)
 		return err
 	}
-	if !stream.IsAutoStream() {
-		h.logger.Infof("updating a stream: %s", stream.Name)
-		h.ys.UpdateStream(stream, true)
-	} else {
+	for _, np := range hub.Current() {
+		if err := send(np); err != nil {
+			return nil
+		}
+	}
+	w.Flush()
 
-		h.logger.Infof("adding autostream: %s", stream.Name)
-		h.ys.UpdateAutoStream(stream)
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case np := <-updates:
+			if err := send(np); err != nil {
+				return nil
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return nil
+			}
+		case <-w.CloseNotify():
+			return nil
+		}
+		w.Flush()
 	}
+}
+
+// nowPlayingWebSocket sends now-playing updates as JSON messages.
+// Updates are filtered by channel slug if it's set
+func (h *HTTPService) nowPlayingWebSocket(c echo.Context) error {
+	slug := c.QueryParam("channel")
+	hub := h.ys.NowPlaying()
+	websocket.Handler(func(ws *websocket.Conn) {
+		defer ws.Close()
+		updates, cancel := hub.Subscribe()
+		defer cancel()
+		closed := make(chan struct{})
+		go func() {
+			// reads until client goes away, incoming messages are ignored
+			var msg string
+			for websocket.Message.Receive(ws, &msg) == nil {
+			}
+			close(closed)
+		}()
+		for _, np := range hub.Current() {
+			if slug == "" || np.Slug == slug {
+				if err := websocket.JSON.Send(ws, np); err != nil {
+					return
+				}
+			}
+		}
+		for {
+			select {
+			case np := <-updates:
+				if slug != "" && np.Slug != slug {
+					continue
+				}
+				if err := websocket.JSON.Send(ws, np); err != nil {
+					return
+				}
+			case <-closed:
+				return
+			}
+		}
+	}).ServeHTTP(c.Response(), c.Request())
 	return nil
 }
+
+func (h *HTTPService) webhooks(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.s.WebhookService().Pending())
+}
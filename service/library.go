@@ -0,0 +1,124 @@
+package service
+
+import (
+	"strings"
+	"time"
+
+	"github.com/maddevsio/yourcast-streamer/service/data"
+	"github.com/maddevsio/yourcast-streamer/stream"
+)
+
+// defaultRescanInterval used for library streams without update frequency, in seconds
+const defaultRescanInterval = 60
+
+func (ys *YoutubeStreamService) createLibraryStream(ls data.Stream) (data.Stream, error) {
+	var patterns []string
+	if ls.Patterns != "" {
+		for _, pattern := range strings.Split(ls.Patterns, ",") {
+			patterns = append(patterns, strings.TrimSpace(pattern))
+		}
+	}
+	files, err := stream.ScanDirectory(ls.Directory, ls.Recursive, patterns)
+	if err != nil {
+		return data.Stream{}, err
+	}
+	streamData := data.Stream{
+		ID:   ls.ID,
+		Name: ls.Name,
+		Slug: ls.Slug,
+	}
+	for _, file := range files {
+		streamData.Links = append(streamData.Links, data.StreamLink{URL: stream.FileLink(file)})
+	}
+	return streamData, nil
+}
+
+func (ys *YoutubeStreamService) runJobsForLibraryStream(ls data.Stream, update bool) {
+	streamData := ys.watchLibraryStream(ls)
+	if !update {
+		ys.AddStream(streamData.ToStreamItem(), false)
+	} else {
+		ys.UpdateStream(streamData, false)
+	}
+}
+
+// watchLibraryStream scans library directory and starts periodic rescans
+// if they are not running yet for the stream
+func (ys *YoutubeStreamService) watchLibraryStream(ls data.Stream) data.Stream {
+	ys.librariesMu.Lock()
+	_, running := ys.libraries[ls.ID]
+	ys.libraries[ls.ID] = ls
+	ys.librariesMu.Unlock()
+
+	streamData, err := ys.createLibraryStream(ls)
+	if err != nil {
+		ys.logger.Errorf("Error while scanning %s for stream %s, %v", ls.Directory, ls.Name, err)
+		streamData = data.Stream{ID: ls.ID, Name: ls.Name, Slug: ls.Slug}
+	}
+	if !running {
+		ys.s.waitGroup.Add(1)
+		go ys.runRescanLibrary(ls.ID, streamData.Links)
+	}
+	return streamData
+}
+
+func (ys *YoutubeStreamService) runRescanLibrary(id int, links []data.StreamLink) {
+	defer ys.s.waitGroup.Done()
+	for {
+		ys.librariesMu.Lock()
+		ls, ok := ys.libraries[id]
+		ys.librariesMu.Unlock()
+		if !ok {
+			return
+		}
+
+		interval := ls.UpdateFrequency
+		if interval <= 0 {
+			interval = defaultRescanInterval
+		}
+		time.Sleep(time.Duration(interval) * time.Second)
+		if ys.IsNeedStop() {
+			return
+		}
+		ys.librariesMu.Lock()
+		ls, ok = ys.libraries[id]
+		ys.librariesMu.Unlock()
+		if !ok {
+			return
+		}
+
+		streamData, err := ys.createLibraryStream(ls)
+		if err != nil {
+			ys.logger.Errorf("Error while scanning %s for stream %s, %v", ls.Directory, ls.Name, err)
+			continue
+		}
+		if sameLinks(links, streamData.Links) {
+			continue
+		}
+		ys.logger.Infof("Library of stream %s changed, %d files found", ls.Name, len(streamData.Links))
+		ys.UpdateStream(streamData, false)
+		links = streamData.Links
+	}
+}
+
+func sameLinks(a, b []data.StreamLink) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].URL != b[i].URL {
+			return false
+		}
+	}
+	return true
+}
+
+// AddLibraryStream adds stream built from local directory
+func (ys *YoutubeStreamService) AddLibraryStream(ls data.Stream) {
+	ys.runJobsForLibraryStream(ls, false)
+}
+
+// UpdateLibraryStream updates stream built from local directory
+func (ys *YoutubeStreamService) UpdateLibraryStream(ls data.Stream) {
+	ys.runJobsForLibraryStream(ls, true)
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/maddevsio/yourcast-streamer/service/data"
+	"github.com/maddevsio/yourcast-streamer/stream"
+)
+
+// getLiveContent returns links of broadcasts that are live now. Links are
+// flagged as live, so they are relayed and never downloaded
+func (ys *YoutubeStreamService) getLiveContent(query string, isChannel bool, as data.Stream) ([]data.StreamLink, error) {
+	results, err := ys.yc.SearchLive(query, isChannel, as.MaxResults, searchFilter(as))
+	if err != nil {
+		return nil, err
+	}
+	as.ExcludeLive = false
+	var links []data.StreamLink
+	for _, item := range filterResults(as, results) {
+		link := videoURL(item.Id.VideoId)
+		ys.liveMu.Lock()
+		if _, ok := ys.live[link]; !ok {
+			ys.live[link] = false
+		}
+		ys.liveMu.Unlock()
+		links = append(links, data.StreamLink{URL: link})
+	}
+	return links, nil
+}
+
+// createLiveLinks returns live broadcasts found by keywords and on channels
+// of autostream
+func (ys *YoutubeStreamService) createLiveLinks(as data.Stream) []data.StreamLink {
+	ys.pruneLive()
+	var links []data.StreamLink
+	seen := make(map[string]bool)
+	queries := []struct {
+		value     string
+		isChannel bool
+	}{{as.Keywords, false}, {as.Channels, true}}
+	for _, q := range queries {
+		if q.value == "" {
+			continue
+		}
+		for _, query := range strings.Split(q.value, ",") {
+			found, err := ys.getLiveContent(query, q.isChannel, as)
+			if err != nil {
+				ys.logger.Errorf("Error while requesting live broadcasts, %v", err)
+				continue
+			}
+			for _, link := range found {
+				if !seen[link.URL] {
+					seen[link.URL] = true
+					links = append(links, link)
+				}
+			}
+		}
+	}
+	return links
+}
+
+// withoutLinks returns links that are not in excluded
+func withoutLinks(links, excluded []data.StreamLink) []data.StreamLink {
+	skip := make(map[string]bool)
+	for _, link := range excluded {
+		skip[link.URL] = true
+	}
+	var filtered []data.StreamLink
+	for _, link := range links {
+		if !skip[link.URL] {
+			filtered = append(filtered, link)
+		}
+	}
+	return filtered
+}
+
+// isLive returns true if link is flagged as live broadcast
+func (ys *YoutubeStreamService) isLive(link string) bool {
+	ys.liveMu.Lock()
+	defer ys.liveMu.Unlock()
+	_, ok := ys.live[link]
+	return ok
+}
+
+// queueLive puts live broadcasts of stream into breaking queue, so they are
+// relayed right after current video
+func (ys *YoutubeStreamService) queueLive(streamID int, links []data.StreamLink) {
+	ys.breakingMu.Lock()
+	defer ys.breakingMu.Unlock()
+	queued := make(map[string]bool)
+	for _, link := range ys.breaking[streamID] {
+		queued[link] = true
+	}
+	for _, link := range links {
+		ys.liveMu.Lock()
+		airing, live := ys.live[link.URL]
+		ys.liveMu.Unlock()
+		if !live || airing || queued[link.URL] {
+			continue
+		}
+		ys.breaking[streamID] = append(ys.breaking[streamID], link.URL)
+		queued[link.URL] = true
+	}
+}
+
+// relayLive relays live broadcast until it ends or limit passes, then
+// removes it from stream. Broadcast that is still live is found again on
+// next refresh of autostream
+func (ys *YoutubeStreamService) relayLive(item *data.StreamItem, link, dstURL string, limit time.Duration) error {
+	defer func() {
+		ys.liveMu.Lock()
+		delete(ys.live, link)
+		ys.liveMu.Unlock()
+		ys.removeLink(item.ID, link)
+	}()
+	src, err := stream.SourceFor(link)
+	if err != nil {
+		ys.logger.Errorf("Got error %s while relaying live %s for channel %s", err, link, item.Name)
+		return err
+	}
+	live, ok := src.(stream.LiveStreamer)
+	if !ok {
+		ys.logger.Errorf("Source %s can't relay live %s for channel %s", src.Name(), link, item.Name)
+		return fmt.Errorf("source %s can't relay live broadcasts", src.Name())
+	}
+	ys.liveMu.Lock()
+	ys.live[link] = true
+	ys.liveMu.Unlock()
+
+	ys.logger.Infof("Relaying live %s on channel %s", link, item.Name)
+	if err := live.StreamLive(ys.s.Config().FFMpegPath, link, dstURL, limit); err != nil {
+		ys.logger.Errorf("Got error %s while relaying live %s for channel %s", err, link, item.Name)
+		return err
+	}
+	ys.logger.Infof("Live %s on channel %s ended, returning to queue", link, item.Name)
+	return nil
+}
+
+// pruneLive forgets broadcasts that are neither airing nor queued by any
+// stream, e.g. broadcasts that ended before they were relayed
+func (ys *YoutubeStreamService) pruneLive() {
+	queued := make(map[string]bool)
+	ys.ss.RLock()
+	for id := range ys.ss.Items {
+		links := ys.ss.Items[id].Links
+		if links == nil {
+			continue
+		}
+		for e := links.Front(); e != nil; e = e.Next() {
+			queued[fmt.Sprintf("%v", e.Value)] = true
+		}
+	}
+	ys.ss.RUnlock()
+	ys.breakingMu.Lock()
+	for _, links := range ys.breaking {
+		for _, link := range links {
+			queued[link] = true
+		}
+	}
+	ys.breakingMu.Unlock()
+
+	ys.liveMu.Lock()
+	for link, airing := range ys.live {
+		if !airing && !queued[link] {
+			delete(ys.live, link)
+		}
+	}
+	ys.liveMu.Unlock()
+}
+
+// removeLink removes link from stream
+func (ys *YoutubeStreamService) removeLink(streamID int, link string) {
+	ys.ss.Lock()
+	defer ys.ss.Unlock()
+	links := ys.ss.Items[streamID].Links
+	if links == nil {
+		return
+	}
+	for e := links.Front(); e != nil; e = e.Next() {
+		if fmt.Sprintf("%v", e.Value) == link {
+			links.Remove(e)
+			return
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/youtube/v3"
+
+	"github.com/maddevsio/yourcast-streamer/bot"
+	"github.com/maddevsio/yourcast-streamer/stream"
+)
+
+// mediaIndexInterval is how often links of queues missing in media index are indexed
+const mediaIndexInterval = time.Minute
+
+// maxProbesPerPass limits number of non-YouTube links probed per indexing pass
+const maxProbesPerPass = 100
+
+// MediaInfo describes title and duration of link
+type MediaInfo struct {
+	Title    string        `json:"title"`
+	Duration time.Duration `json:"duration"`
+}
+
+// MediaIndex stores titles and durations of links and persists them on disk.
+// YouTube links are stored by video, so every form of video link shares entry
+type MediaIndex struct {
+	sync.Mutex
+	path  string
+	Media map[string]MediaInfo `json:"media"`
+}
+
+// NewMediaIndex loads index from path. Missing file means empty index
+func NewMediaIndex(path string) (*MediaIndex, error) {
+	m := &MediaIndex{path: path, Media: make(map[string]MediaInfo)}
+	body, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(body, m); err != nil {
+		return nil, err
+	}
+	if m.Media == nil {
+		m.Media = make(map[string]MediaInfo)
+	}
+	return m, nil
+}
+
+// Get returns title and duration of link
+func (m *MediaIndex) Get(link string) (MediaInfo, bool) {
+	m.Lock()
+	defer m.Unlock()
+	info, ok := m.Media[mediaKey(link)]
+	return info, ok
+}
+
+// Set stores title and duration of link
+func (m *MediaIndex) Set(link string, info MediaInfo) {
+	m.Lock()
+	m.Media[mediaKey(link)] = info
+	m.Unlock()
+}
+
+// Save writes index to disk
+func (m *MediaIndex) Save() error {
+	m.Lock()
+	defer m.Unlock()
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	tmp := m.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, body, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.path)
+}
+
+// mediaKey returns canonical url of YouTube video, other links are kept as is
+func mediaKey(link string) string {
+	if videoID := videoIDByURL(link); videoID != "" {
+		return videoURL(videoID)
+	}
+	return link
+}
+
+// videoIDByURL returns id of YouTube video or empty string if link is not
+// a YouTube video
+func videoIDByURL(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	switch strings.TrimPrefix(strings.ToLower(u.Host), "www.") {
+	case "youtube.com", "m.youtube.com":
+		return u.Query().Get("v")
+	case "youtu.be":
+		return strings.TrimPrefix(u.Path, "/")
+	}
+	return ""
+}
+
+// indexVideos stores titles and durations of videos returned by YouTube Data API
+func (ys *YoutubeStreamService) indexVideos(videos []*youtube.Video) {
+	for _, video := range videos {
+		if video.Snippet == nil || video.ContentDetails == nil {
+			continue
+		}
+		duration, err := bot.ParseDuration(video.ContentDetails.Duration)
+		if err != nil {
+			continue
+		}
+		ys.media.Set(videoURL(video.Id), MediaInfo{Title: video.Snippet.Title, Duration: duration})
+	}
+}
+
+// indexQueues indexes links of every queue that are missing in media index.
+// YouTube videos are requested in batches, other links are probed with ffprobe
+func (ys *YoutubeStreamService) indexQueues() {
+	seen := make(map[string]bool)
+	var ids, others []string
+	ys.ss.RLock()
+	for id := range ys.ss.Items {
+		for e := ys.ss.Items[id].Links.Front(); e != nil; e = e.Next() {
+			link := fmt.Sprintf("%v", e.Value)
+			if seen[mediaKey(link)] {
+				continue
+			}
+			seen[mediaKey(link)] = true
+			if _, ok := ys.media.Get(link); ok {
+				continue
+			}
+			if videoID := videoIDByURL(link); videoID != "" {
+				ids = append(ids, videoID)
+			} else {
+				others = append(others, link)
+			}
+		}
+	}
+	ys.ss.RUnlock()
+	if len(ids) == 0 && len(others) == 0 {
+		return
+	}
+	if len(ids) > 0 {
+		videos, err := ys.yc.Videos(ids)
+		if err != nil {
+			ys.logger.Errorf("Error while requesting video details, %v", err)
+		}
+		ys.indexVideos(videos)
+	}
+	if len(others) > maxProbesPerPass {
+		others = others[:maxProbesPerPass]
+	}
+	for _, link := range others {
+		ys.probeMedia(link)
+	}
+	if err := ys.media.Save(); err != nil {
+		ys.logger.Errorf("Got error %s while saving media index", err)
+	}
+}
+
+func (ys *YoutubeStreamService) probeMedia(link string) {
+	src, err := stream.SourceFor(link)
+	if err != nil {
+		return
+	}
+	media, err := src.Probe(link)
+	if err != nil {
+		ys.logger.Debugf("Got error %s while probing %s", err, link)
+		return
+	}
+	duration, err := stream.ProbeDuration(ys.s.Config().FFProbePath, media.URL)
+	if err != nil {
+		ys.logger.Debugf("Got error %s while probing duration of %s", err, link)
+		return
+	}
+	ys.media.Set(link, MediaInfo{Title: media.Title, Duration: duration})
+}
+
+func (ys *YoutubeStreamService) runIndexMedia() {
+	defer ys.s.waitGroup.Done()
+	for !ys.IsNeedStop() {
+		ys.indexQueues()
+		time.Sleep(mediaIndexInterval)
+	}
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMediaIndexYoutubeLinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "media")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	m, err := NewMediaIndex(filepath.Join(dir, "media.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Set(videoURL("video1"), MediaInfo{Title: "Video", Duration: time.Minute})
+	for _, link := range []string{
+		"https://youtube.com/watch?v=video1",
+		"https://www.youtube.com/watch?v=video1",
+		"https://www.youtube.com/watch?v=video1&t=42s&list=playlist",
+		"https://m.youtube.com/watch?v=video1",
+		"https://youtu.be/video1",
+	} {
+		if info, ok := m.Get(link); !ok || info.Title != "Video" {
+			t.Fatalf("expected indexed video for %s, got %+v", link, info)
+		}
+	}
+	if _, ok := m.Get("https://www.youtube.com/watch?v=video2"); ok {
+		t.Fatal("other video must not be found")
+	}
+
+	m.Set("file:///media/filler/ident.mp4", MediaInfo{Title: "Ident"})
+	if _, ok := m.Get("file:///media/filler/ident.mp4"); !ok {
+		t.Fatal("other links must be stored as is")
+	}
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/maddevsio/yourcast-streamer/service/data"
+)
+
+// nextUpCount is number of next-up items in now-playing feed
+const nextUpCount = 3
+
+// subscriberBuffer is number of updates buffered for slow subscriber,
+// updates that don't fit are dropped
+const subscriberBuffer = 16
+
+// NowPlaying describes video airing on channel
+type NowPlaying struct {
+	ChannelID int       `json:"channel_id"`
+	Channel   string    `json:"channel"`
+	Slug      string    `json:"slug"`
+	VideoID   string    `json:"video_id,omitempty"`
+	URL       string    `json:"url"`
+	Title     string    `json:"title"`
+	StartedAt time.Time `json:"started_at"`
+	Duration  int       `json:"duration"`
+	Next      []NextUp  `json:"next"`
+}
+
+// NextUp describes video queued after current one
+type NextUp struct {
+	VideoID  string `json:"video_id,omitempty"`
+	URL      string `json:"url"`
+	Title    string `json:"title"`
+	Duration int    `json:"duration"`
+}
+
+// metadata returns now-playing data embedded in output
+func (np NowPlaying) metadata() map[string]string {
+	metadata := map[string]string{
+		"title":      np.Title,
+		"channel":    np.Channel,
+		"started_at": np.StartedAt.Format(time.RFC3339),
+	}
+	if np.VideoID != "" {
+		metadata["video_id"] = np.VideoID
+	}
+	if np.Duration > 0 {
+		metadata["duration"] = strconv.Itoa(np.Duration)
+	}
+	return metadata
+}
+
+// NowPlayingHub keeps current video of every channel and fans updates
+// out to subscribers
+type NowPlayingHub struct {
+	sync.Mutex
+	current     map[int]NowPlaying
+	subscribers map[chan NowPlaying]bool
+}
+
+// NewNowPlayingHub creates empty hub
+func NewNowPlayingHub() *NowPlayingHub {
+	return &NowPlayingHub{
+		current:     make(map[int]NowPlaying),
+		subscribers: make(map[chan NowPlaying]bool),
+	}
+}
+
+// Publish stores current video of channel and sends it to subscribers
+func (h *NowPlayingHub) Publish(np NowPlaying) {
+	h.Lock()
+	defer h.Unlock()
+	h.current[np.ChannelID] = np
+	for ch := range h.subscribers {
+		select {
+		case ch <- np:
+		default:
+		}
+	}
+}
+
+// Remove forgets channel
+func (h *NowPlayingHub) Remove(channel int) {
+	h.Lock()
+	delete(h.current, channel)
+	h.Unlock()
+}
+
+// Current returns current video of every channel sorted by channel id
+func (h *NowPlayingHub) Current() []NowPlaying {
+	h.Lock()
+	defer h.Unlock()
+	current := make([]NowPlaying, 0, len(h.current))
+	for _, np := range h.current {
+		current = append(current, np)
+	}
+	sort.Slice(current, func(i, j int) bool {
+		return current[i].ChannelID < current[j].ChannelID
+	})
+	return current
+}
+
+// Subscribe returns channel of updates and function that cancels subscription
+func (h *NowPlayingHub) Subscribe() (<-chan NowPlaying, func()) {
+	ch := make(chan NowPlaying, subscriberBuffer)
+	h.Lock()
+	h.subscribers[ch] = true
+	h.Unlock()
+	return ch, func() {
+		h.Lock()
+		delete(h.subscribers, ch)
+		h.Unlock()
+	}
+}
+
+// nowPlaying describes link that starts playing on channel. Duration is
+// what is left of video after offset, cut by limit
+func (ys *YoutubeStreamService) nowPlaying(item *data.StreamItem, link string, offset, limit time.Duration) NowPlaying {
+	info, _ := ys.media.Get(link)
+	np := NowPlaying{
+		ChannelID: item.ID,
+		Channel:   item.Name,
+		Slug:      item.Slug,
+		VideoID:   videoIDByURL(link),
+		URL:       link,
+		Title:     info.Title,
+		StartedAt: time.Now(),
+	}
+	if np.Title == "" {
+		np.Title = link
+	}
+	duration := info.Duration - offset
+	if limit > 0 && (duration <= 0 || limit < duration) {
+		duration = limit
+	}
+	if duration > 0 {
+		np.Duration = int(duration.Seconds())
+	}
+
+	item.RLock()
+	var queue []string
+	current := -1
+	for e := item.Links.Front(); e != nil; e = e.Next() {
+		value := fmt.Sprintf("%v", e.Value)
+		if value == link && current < 0 {
+			current = len(queue)
+		}
+		queue = append(queue, value)
+	}
+	item.RUnlock()
+	for i := 1; current >= 0 && i <= nextUpCount && i < len(queue); i++ {
+		next := queue[(current+i)%len(queue)]
+		nextInfo, _ := ys.media.Get(next)
+		up := NextUp{
+			VideoID:  videoIDByURL(next),
+			URL:      next,
+			Title:    nextInfo.Title,
+			Duration: int(nextInfo.Duration.Seconds()),
+		}
+		if up.Title == "" {
+			up.Title = next
+		}
+		np.Next = append(np.Next, up)
+	}
+	return np
+}
+
+// NowPlaying returns hub of now-playing updates
+func (ys *YoutubeStreamService) NowPlaying() *NowPlayingHub {
+	return ys.playing
+}
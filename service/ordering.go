@@ -0,0 +1,201 @@
+package service
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/api/youtube/v3"
+
+	"github.com/maddevsio/yourcast-streamer/service/data"
+)
+
+// OrderRandom, OrderNewest, OrderMostViewed, OrderLikeRatio, OrderRoundRobin
+// and OrderWeighted are ordering strategies of autostream candidates
+const (
+	OrderRandom     = "random"
+	OrderNewest     = "newest"
+	OrderMostViewed = "most_viewed"
+	OrderLikeRatio  = "like_ratio"
+	OrderRoundRobin = "round_robin"
+	OrderWeighted   = "weighted"
+)
+
+// freshnessHalfLife is age when freshness part of weighted score halves
+const freshnessHalfLife = 24 * time.Hour
+
+// candidate is a video found for autostream by keyword or channel
+type candidate struct {
+	VideoID     string
+	Source      string
+	Weight      float64
+	PublishedAt time.Time
+	Views       uint64
+	Likes       uint64
+}
+
+func (c candidate) link() data.StreamLink {
+	return data.StreamLink{URL: videoURL(c.VideoID)}
+}
+
+// score mixes freshness and popularity of candidate, multiplied by its weight
+func (c candidate) score(now time.Time) float64 {
+	age := now.Sub(c.PublishedAt)
+	freshness := math.Exp(-math.Ln2 * float64(age) / float64(freshnessHalfLife))
+	popularity := math.Min(math.Log10(float64(c.Views)+1)/7, 1)
+	return c.Weight * (freshness + popularity) / 2
+}
+
+func (c candidate) likeRatio() float64 {
+	if c.Views == 0 {
+		return 0
+	}
+	return float64(c.Likes) / float64(c.Views)
+}
+
+// sourceWeight returns weight of keyword or channel, 1 by default
+func sourceWeight(as data.Stream, source string) float64 {
+	if w, ok := as.Weights[strings.TrimSpace(source)]; ok && w > 0 {
+		return w
+	}
+	return 1
+}
+
+// toCandidates converts search results found by source into candidates
+func toCandidates(as data.Stream, source string, results []*youtube.SearchResult) []candidate {
+	weight := sourceWeight(as, source)
+	candidates := make([]candidate, 0, len(results))
+	for _, item := range results {
+		c := candidate{
+			VideoID: item.Id.VideoId,
+			Source:  source,
+			Weight:  weight,
+		}
+		if item.Snippet != nil {
+			c.PublishedAt, _ = time.Parse(time.RFC3339, item.Snippet.PublishedAt)
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates
+}
+
+// orderCandidates orders candidates by strategy of autostream.
+// Weights multiply metric used by strategy, for round robin weight is
+// number of videos taken from source per turn
+func (ys *YoutubeStreamService) orderCandidates(as data.Stream, candidates []candidate) []data.StreamLink {
+	switch as.Ordering {
+	case OrderMostViewed, OrderLikeRatio, OrderWeighted:
+		ys.fillStatistics(candidates)
+	}
+	now := time.Now()
+	switch as.Ordering {
+	case OrderNewest:
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].PublishedAt.After(candidates[j].PublishedAt)
+		})
+	case OrderMostViewed:
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return float64(candidates[i].Views)*candidates[i].Weight > float64(candidates[j].Views)*candidates[j].Weight
+		})
+	case OrderLikeRatio:
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].likeRatio()*candidates[i].Weight > candidates[j].likeRatio()*candidates[j].Weight
+		})
+	case OrderWeighted:
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].score(now) > candidates[j].score(now)
+		})
+	case OrderRoundRobin:
+		candidates = roundRobin(candidates)
+	default:
+		candidates = weightedShuffle(candidates)
+	}
+	links := make([]data.StreamLink, 0, len(candidates))
+	seen := make(map[string]bool)
+	for _, c := range candidates {
+		if seen[c.VideoID] {
+			continue
+		}
+		seen[c.VideoID] = true
+		links = append(links, c.link())
+	}
+	return links
+}
+
+// fillStatistics requests view and like counts of candidates
+func (ys *YoutubeStreamService) fillStatistics(candidates []candidate) {
+	var ids []string
+	for _, c := range candidates {
+		ids = append(ids, c.VideoID)
+	}
+	if len(ids) == 0 {
+		return
+	}
+	videos, err := ys.yc.Videos(ids)
+	if err != nil {
+		ys.logger.Errorf("Error while requesting video statistics, %v", err)
+		return
+	}
+	ys.indexVideos(videos)
+	for _, video := range videos {
+		if video.Statistics == nil {
+			continue
+		}
+		for i := range candidates {
+			if candidates[i].VideoID == video.Id {
+				candidates[i].Views = video.Statistics.ViewCount
+				candidates[i].Likes = video.Statistics.LikeCount
+			}
+		}
+	}
+}
+
+// weightedShuffle shuffles candidates so that ones with bigger weight
+// are more likely to be placed first
+func weightedShuffle(candidates []candidate) []candidate {
+	keys := make([]float64, len(candidates))
+	idx := make([]int, len(candidates))
+	for i, c := range candidates {
+		idx[i] = i
+		keys[i] = math.Pow(rand.Float64(), 1/c.Weight)
+	}
+	sort.Slice(idx, func(i, j int) bool {
+		return keys[idx[i]] > keys[idx[j]]
+	})
+	shuffled := make([]candidate, len(candidates))
+	for i, j := range idx {
+		shuffled[i] = candidates[j]
+	}
+	return shuffled
+}
+
+// roundRobin takes videos from every source in turn, keeping order of
+// videos within source
+func roundRobin(candidates []candidate) []candidate {
+	var sources []string
+	bySource := make(map[string][]candidate)
+	for _, c := range candidates {
+		if _, ok := bySource[c.Source]; !ok {
+			sources = append(sources, c.Source)
+		}
+		bySource[c.Source] = append(bySource[c.Source], c)
+	}
+	ordered := make([]candidate, 0, len(candidates))
+	for len(ordered) < len(candidates) {
+		for _, source := range sources {
+			queue := bySource[source]
+			if len(queue) == 0 {
+				continue
+			}
+			take := int(math.Max(1, math.Round(queue[0].Weight)))
+			if take > len(queue) {
+				take = len(queue)
+			}
+			ordered = append(ordered, queue[:take]...)
+			bySource[source] = queue[take:]
+		}
+	}
+	return ordered
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// maxDownloadAttempts limits how many times corrupt video is downloaded again
+const maxDownloadAttempts = 3
+
+// QuarantineEntry describes downloaded file that failed verification
+type QuarantineEntry struct {
+	URL     string    `json:"url"`
+	File    string    `json:"file"`
+	Reason  string    `json:"reason"`
+	Attempt int       `json:"attempt"`
+	Time    time.Time `json:"time"`
+}
+
+// QuarantineLog stores reports about quarantined files
+type QuarantineLog struct {
+	sync.RWMutex
+	entries []QuarantineEntry
+}
+
+// Add adds entry into log
+func (q *QuarantineLog) Add(entry QuarantineEntry) {
+	q.Lock()
+	q.entries = append(q.entries, entry)
+	q.Unlock()
+}
+
+// Entries returns copy of all entries
+func (q *QuarantineLog) Entries() []QuarantineEntry {
+	q.RLock()
+	defer q.RUnlock()
+	entries := make([]QuarantineEntry, len(q.entries))
+	copy(entries, q.entries)
+	return entries
+}
+
+type downloadJob struct {
+	url     string
+	attempt int
+}
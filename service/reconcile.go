@@ -0,0 +1,278 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/maddevsio/yourcast-streamer/service/data"
+)
+
+// ReconcileReport describes outcome of reconciliation with Web UI catalog
+type ReconcileReport struct {
+	Time    time.Time `json:"time"`
+	Error   string    `json:"error,omitempty"`
+	Added   []int     `json:"added"`
+	Updated []int     `json:"updated"`
+	Removed []int     `json:"removed"`
+	Invalid []int     `json:"invalid"`
+}
+
+// ErrStreamExists is returned when stream with the same id is already defined
+var ErrStreamExists = errors.New("stream already exists")
+
+// Origins of stream definitions. Reconciliation updates and removes only
+// streams of Web UI and channels file, streams of API are kept as is
+const (
+	OriginWebUI = "webui"
+	OriginFile  = "file"
+	OriginAPI   = "api"
+)
+
+func (ys *YoutubeStreamService) setDefinition(stream data.Stream, origin string) {
+	ys.streamsMu.Lock()
+	ys.definitions[stream.ID] = stream
+	ys.origins[stream.ID] = origin
+	ys.streamsMu.Unlock()
+}
+
+func (ys *YoutubeStreamService) origin(id int) string {
+	ys.streamsMu.Lock()
+	defer ys.streamsMu.Unlock()
+	return ys.origins[id]
+}
+
+func (ys *YoutubeStreamService) definition(id int) (data.Stream, bool) {
+	ys.streamsMu.Lock()
+	defer ys.streamsMu.Unlock()
+	stream, ok := ys.definitions[id]
+	return stream, ok
+}
+
+func (ys *YoutubeStreamService) definitionIDs() []int {
+	ys.streamsMu.Lock()
+	defer ys.streamsMu.Unlock()
+	ids := make([]int, 0, len(ys.definitions))
+	for id := range ys.definitions {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// Stream returns definition of stream by id
+func (ys *YoutubeStreamService) Stream(id int) (data.Stream, bool) {
+	return ys.definition(id)
+}
+
+// Streams returns definitions of every stream ordered by id
+func (ys *YoutubeStreamService) Streams() []data.Stream {
+	ids := ys.definitionIDs()
+	streams := make([]data.Stream, 0, len(ids))
+	for _, id := range ids {
+		if stream, ok := ys.definition(id); ok {
+			streams = append(streams, stream)
+		}
+	}
+	return streams
+}
+
+// ApplyStream adds or updates stream by its definition coming from origin.
+// Update of stream that is not in storage yet adds it. Invalid streams are
+// not applied
+func (ys *YoutubeStreamService) ApplyStream(stream data.Stream, update bool, origin string) error {
+	if err := ValidateStream(stream); err != nil {
+		return err
+	}
+	ys.setDefinition(stream, origin)
+	ys.applyStream(stream, update)
+	return nil
+}
+
+// CreateStream adds stream of API if there is no stream with the same id,
+// ErrStreamExists is returned otherwise
+func (ys *YoutubeStreamService) CreateStream(stream data.Stream) error {
+	if err := ValidateStream(stream); err != nil {
+		return err
+	}
+	ys.streamsMu.Lock()
+	if _, ok := ys.definitions[stream.ID]; ok {
+		ys.streamsMu.Unlock()
+		return ErrStreamExists
+	}
+	ys.definitions[stream.ID] = stream
+	ys.origins[stream.ID] = OriginAPI
+	ys.streamsMu.Unlock()
+	ys.applyStream(stream, false)
+	return nil
+}
+
+func (ys *YoutubeStreamService) applyStream(stream data.Stream, update bool) {
+	if update {
+		ys.ss.RLock()
+		update = ys.ss.Items[stream.ID].Links != nil
+		ys.ss.RUnlock()
+	}
+	switch {
+	case stream.IsLibraryStream() && update:
+		ys.logger.Infof("Updating library stream: %s", stream.Name)
+		ys.UpdateLibraryStream(stream)
+	case stream.IsLibraryStream():
+		ys.logger.Infof("Adding library stream: %s", stream.Name)
+		ys.AddLibraryStream(stream)
+	case stream.IsAutoStream() && update:
+		ys.logger.Infof("Updating autostream: %s", stream.Name)
+		ys.UpdateAutoStream(stream)
+	case stream.IsAutoStream():
+		ys.logger.Infof("Adding autostream: %s", stream.Name)
+		ys.AddAutoStream(stream)
+	case update:
+		ys.logger.Infof("Updating a stream: %s", stream.Name)
+		ys.UpdateStream(stream, true)
+	default:
+		ys.logger.Infof("Adding a new stream: %s", stream.Name)
+		ys.AddStream(stream.ToStreamItem(), true)
+	}
+}
+
+// validStreams returns streams that pass validation, invalid ones are logged
+func (ys *YoutubeStreamService) validStreams(streams []data.Stream) []data.Stream {
+	valid := make([]data.Stream, 0, len(streams))
+	for _, stream := range streams {
+		if err := ValidateStream(stream); err != nil {
+			ys.logger.Errorf("Got error %s while validating stream %d %s, skipping it", err, stream.ID, stream.Name)
+			continue
+		}
+		valid = append(valid, stream)
+	}
+	return valid
+}
+
+// RemoveStream removes stream from storage. Streaming stops after current
+// video, refresh and rescans of stream stop too
+func (ys *YoutubeStreamService) RemoveStream(id int) bool {
+	ys.streamsMu.Lock()
+	stream, ok := ys.definitions[id]
+	delete(ys.definitions, id)
+	delete(ys.origins, id)
+	if stop, running := ys.stops[id]; running {
+		close(stop)
+		delete(ys.stops, id)
+	}
+	if stop, running := ys.refreshes[id]; running {
+		close(stop)
+		delete(ys.refreshes, id)
+	}
+	ys.streamsMu.Unlock()
+
+	ys.ss.Lock()
+	delete(ys.ss.Items, id)
+	ys.ss.Unlock()
+
+	ys.librariesMu.Lock()
+	delete(ys.libraries, id)
+	ys.librariesMu.Unlock()
+
+	ys.breakingMu.Lock()
+	delete(ys.breaking, id)
+	ys.breakingMu.Unlock()
+
+	ys.state.Remove(id)
+	ys.playing.Remove(id)
+
+	if stream.IsNews {
+		ys.s.WebSubService().SetStreamChannels(id, nil)
+	}
+	if ok {
+		ys.logger.Infof("Stream %s removed", stream.Name)
+	}
+	return ok
+}
+
+// Reconcile fetches Web UI catalog and applies streams that were added,
+// changed or removed since last time. Nothing is removed if catalog can't
+// be fetched
+func (ys *YoutubeStreamService) Reconcile() ReconcileReport {
+	report := ReconcileReport{Time: time.Now()}
+	remote, err := ys.getStreams()
+	if err == nil {
+		if err := ys.saveSnapshot(remote); err != nil {
+			ys.logger.Errorf("Got error %s while saving snapshot", err)
+		}
+		var local []data.Stream
+		local, err = ys.loadChannelsFile()
+		if err == nil {
+			streams, origins := ys.mergeStreams(remote, local)
+			ys.reconcile(streams, origins, &report)
+		}
+	}
+	if err != nil {
+		ys.logger.Errorf("Got error %s while reconciling streams with Web UI", err)
+		report.Error = err.Error()
+	} else {
+		ys.logger.Infof(
+			"Streams reconciled with Web UI: %d added, %d updated, %d removed, %d invalid",
+			len(report.Added), len(report.Updated), len(report.Removed), len(report.Invalid),
+		)
+	}
+	ys.reconcileMu.Lock()
+	ys.reconciled = &report
+	ys.reconcileMu.Unlock()
+	return report
+}
+
+// reconcile applies streams that were added or changed and removes missing
+// ones. Invalid streams are skipped, current version of them keeps running.
+// Streams of API are neither overwritten nor removed
+func (ys *YoutubeStreamService) reconcile(streams []data.Stream, origins map[int]string, report *ReconcileReport) {
+	wanted := make(map[int]bool)
+	for _, stream := range streams {
+		wanted[stream.ID] = true
+		current, ok := ys.definition(stream.ID)
+		if ok && ys.origin(stream.ID) == OriginAPI {
+			ys.logger.Debugf("Stream %d is managed through API, skipping catalog version", stream.ID)
+			continue
+		}
+		if ok && reflect.DeepEqual(current, stream) {
+			ys.setDefinition(stream, origins[stream.ID])
+			continue
+		}
+		if err := ys.ApplyStream(stream, ok, origins[stream.ID]); err != nil {
+			ys.logger.Errorf("Got error %s while validating stream %d %s, skipping it", err, stream.ID, stream.Name)
+			report.Invalid = append(report.Invalid, stream.ID)
+		} else if ok {
+			report.Updated = append(report.Updated, stream.ID)
+		} else {
+			report.Added = append(report.Added, stream.ID)
+		}
+	}
+	for _, id := range ys.definitionIDs() {
+		if !wanted[id] && ys.origin(id) != OriginAPI && ys.RemoveStream(id) {
+			report.Removed = append(report.Removed, id)
+		}
+	}
+}
+
+// LastReconcile returns report of last reconciliation or nil if there was none
+func (ys *YoutubeStreamService) LastReconcile() *ReconcileReport {
+	ys.reconcileMu.Lock()
+	defer ys.reconcileMu.Unlock()
+	return ys.reconciled
+}
+
+func (ys *YoutubeStreamService) runReconcile() {
+	defer ys.s.waitGroup.Done()
+	for {
+		interval := ys.s.Config().ReconcileInterval
+		if interval <= 0 {
+			ys.logger.Info("Reconcile interval is not set, periodic reconciliation is disabled")
+			return
+		}
+		time.Sleep(time.Duration(interval) * time.Second)
+		if ys.IsNeedStop() {
+			return
+		}
+		ys.Reconcile()
+	}
+}
@@ -0,0 +1,292 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/maddevsio/yourcast-streamer/service/data"
+)
+
+// scheduleLookahead limits how far next airing of block is searched
+const scheduleLookahead = 8 * 24 * time.Hour
+
+// maxScheduleBackoff limits pause between retries of failing block
+const maxScheduleBackoff = time.Minute
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// Airing is scheduled block airing at specific time
+type Airing struct {
+	Block data.ScheduleBlock
+	Start time.Time
+	End   time.Time
+}
+
+// Title returns title of block, name of its content is used if it's not set
+func (a Airing) Title() string {
+	if a.Block.Title != "" {
+		return a.Block.Title
+	}
+	return a.Block.Content.Name
+}
+
+// EPGEntry describes programme airing on channel
+type EPGEntry struct {
+	Title     string    `json:"title"`
+	Start     time.Time `json:"start"`
+	Stop      time.Time `json:"stop"`
+	Scheduled bool      `json:"scheduled"`
+}
+
+// EPGChannel is a row of EPG grid
+type EPGChannel struct {
+	ID      int        `json:"id"`
+	Name    string     `json:"name"`
+	Slug    string     `json:"slug"`
+	Entries []EPGEntry `json:"entries"`
+}
+
+func scheduleLocation(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(timezone)
+}
+
+// validateSchedule checks timezone and every block of stream schedule
+func validateSchedule(as data.Stream) error {
+	if _, err := scheduleLocation(as.Timezone); err != nil {
+		return err
+	}
+	for i, block := range as.Schedule {
+		if _, err := time.Parse("15:04", block.Start); err != nil {
+			return fmt.Errorf("block %d: invalid start %q", i, block.Start)
+		}
+		if block.Duration <= 0 {
+			return fmt.Errorf("block %d: duration must be positive", i)
+		}
+		for _, weekday := range block.Weekdays {
+			if _, ok := weekdayNames[strings.ToLower(weekday)]; !ok {
+				return fmt.Errorf("block %d: unknown weekday %q", i, weekday)
+			}
+		}
+		for _, date := range block.Dates {
+			if _, err := time.Parse("2006-01-02", date); err != nil {
+				return fmt.Errorf("block %d: invalid date %q", i, date)
+			}
+		}
+	}
+	return nil
+}
+
+// airings returns airings of stream schedule that overlap [from, to),
+// sorted by start. Invalid blocks are skipped
+func airings(as data.Stream, from, to time.Time) []Airing {
+	loc, err := scheduleLocation(as.Timezone)
+	if err != nil {
+		return nil
+	}
+	var result []Airing
+	for _, block := range as.Schedule {
+		clock, err := time.Parse("15:04", block.Start)
+		if err != nil || block.Duration <= 0 {
+			continue
+		}
+		duration := time.Duration(block.Duration) * time.Second
+		// blocks started on previous days may still be airing
+		first := from.In(loc).Add(-duration).AddDate(0, 0, -1)
+		for day := first; day.Before(to); day = day.AddDate(0, 0, 1) {
+			if !airsOn(block, day) {
+				continue
+			}
+			start := time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), 0, 0, loc)
+			end := start.Add(duration)
+			if end.After(from) && start.Before(to) {
+				result = append(result, Airing{Block: block, Start: start, End: end})
+			}
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Start.Before(result[j].Start)
+	})
+	return result
+}
+
+func airsOn(block data.ScheduleBlock, day time.Time) bool {
+	if len(block.Weekdays) == 0 && len(block.Dates) == 0 {
+		return true
+	}
+	for _, weekday := range block.Weekdays {
+		if wd, ok := weekdayNames[strings.ToLower(weekday)]; ok && wd == day.Weekday() {
+			return true
+		}
+	}
+	date := day.Format("2006-01-02")
+	for _, d := range block.Dates {
+		if d == date {
+			return true
+		}
+	}
+	return false
+}
+
+// currentAiring returns block airing on stream now. If blocks overlap the
+// one that started earlier airs first
+func (ys *YoutubeStreamService) currentAiring(id int, now time.Time) (Airing, bool) {
+	as, ok := ys.definition(id)
+	if !ok || len(as.Schedule) == 0 {
+		return Airing{}, false
+	}
+	for _, airing := range airings(as, now, now.Add(time.Second)) {
+		if !airing.Start.After(now) {
+			return airing, true
+		}
+	}
+	return Airing{}, false
+}
+
+// untilNextAiring returns time left till next block of stream starts,
+// zero if stream has no upcoming blocks
+func (ys *YoutubeStreamService) untilNextAiring(id int) time.Duration {
+	as, ok := ys.definition(id)
+	if !ok || len(as.Schedule) == 0 {
+		return 0
+	}
+	now := time.Now()
+	for _, airing := range airings(as, now, now.Add(scheduleLookahead)) {
+		if airing.Start.After(now) {
+			return airing.Start.Sub(now)
+		}
+	}
+	return 0
+}
+
+// blockLinks returns links of block content. Autostream and library content
+// is collected the same way as for streams
+func (ys *YoutubeStreamService) blockLinks(id int, block data.ScheduleBlock) []data.StreamLink {
+	content := block.Content
+	content.ID = id
+	switch {
+	case content.IsLibraryStream():
+		streamData, err := ys.createLibraryStream(content)
+		if err != nil {
+			ys.logger.Errorf("Error while scanning %s for block %s, %v", content.Directory, block.Title, err)
+			return nil
+		}
+		return streamData.Links
+	case content.IsAutoStream():
+		return ys.createStream(content).Links
+	}
+	return content.Links
+}
+
+// playScheduled plays block airing now on stream until block ends and
+// returns false if no block is airing. Content is trimmed to end of block,
+// remaining time is padded with filler or default queue
+func (ys *YoutubeStreamService) playScheduled(item *data.StreamItem, stop <-chan struct{}) bool {
+	airing, ok := ys.currentAiring(item.ID, time.Now())
+	if !ok {
+		return false
+	}
+	ys.logger.Infof("Airing block %s on channel %s until %s", airing.Title(), item.Name, airing.End.Format(time.RFC3339))
+	links := ys.blockLinks(item.ID, airing.Block)
+	as, _ := ys.definition(item.ID)
+	filler := as.Filler
+	if len(filler) == 0 {
+		item.RLock()
+		for e := item.Links.Front(); e != nil; e = e.Next() {
+			filler = append(filler, data.StreamLink{URL: fmt.Sprintf("%v", e.Value)})
+		}
+		item.RUnlock()
+	}
+	failures := 0
+	for i := 0; !stopped(stop); i++ {
+		remaining := time.Until(airing.End)
+		if remaining < time.Second {
+			break
+		}
+		var link string
+		switch {
+		case i < len(links):
+			link = links[i].URL
+		case len(filler) > 0:
+			link = filler[(i-len(links))%len(filler)].URL
+		default:
+			sleepUnlessStopped(stop, remaining)
+			continue
+		}
+		if err := ys.playLink(item, link, 0, remaining); err == nil {
+			failures = 0
+			continue
+		}
+		failures++
+		if i >= len(links) && failures >= len(filler) {
+			sleepUnlessStopped(stop, scheduleBackoff(failures, time.Until(airing.End)))
+		}
+	}
+	return true
+}
+
+// scheduleBackoff returns pause after every filler link failed in a row,
+// pause doubles with failures up to a minute and never exceeds remaining time
+func scheduleBackoff(failures int, remaining time.Duration) time.Duration {
+	backoff := maxScheduleBackoff
+	if failures < 7 {
+		backoff = time.Duration(1<<uint(failures-1)) * time.Second
+	}
+	if backoff > remaining {
+		backoff = remaining
+	}
+	return backoff
+}
+
+// EPG returns programme of every stream between from and to. Time outside
+// of scheduled blocks is filled with entries of default queue
+func (ys *YoutubeStreamService) EPG(from, to time.Time) []EPGChannel {
+	var channels []EPGChannel
+	for _, id := range ys.definitionIDs() {
+		as, ok := ys.definition(id)
+		if !ok {
+			continue
+		}
+		channel := EPGChannel{ID: as.ID, Name: as.Name, Slug: as.Slug}
+		cursor := from
+		for _, airing := range airings(as, from, to) {
+			start, end := airing.Start, airing.End
+			if start.Before(cursor) {
+				start = cursor
+			}
+			if end.After(to) {
+				end = to
+			}
+			if !end.After(start) {
+				continue
+			}
+			if start.After(cursor) {
+				channel.Entries = append(channel.Entries, EPGEntry{Title: as.Name, Start: cursor, Stop: start})
+			}
+			channel.Entries = append(channel.Entries, EPGEntry{
+				Title:     airing.Title(),
+				Start:     start,
+				Stop:      end,
+				Scheduled: true,
+			})
+			cursor = end
+		}
+		if to.After(cursor) {
+			channel.Entries = append(channel.Entries, EPGEntry{Title: as.Name, Start: cursor, Stop: to})
+		}
+		channels = append(channels, channel)
+	}
+	return channels
+}
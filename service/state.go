@@ -0,0 +1,239 @@
+package service
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/maddevsio/yourcast-streamer/service/data"
+)
+
+// stateCheckpointInterval is how often channel state is saved to disk.
+// Resumed playhead may lag behind by this interval
+const stateCheckpointInterval = 10 * time.Second
+
+// ChannelState is runtime state of channel kept between restarts
+type ChannelState struct {
+	Current   string    `json:"current"`
+	StartedAt time.Time `json:"started_at"`
+	Queue     []string  `json:"queue"`
+}
+
+// StateStore stores state of every channel and quarantined files and
+// persists them on disk. State is small and is rewritten whole, so it's
+// kept in JSON file replaced atomically rather than in embedded database,
+// which would be another vendored dependency for a few kilobytes
+type StateStore struct {
+	sync.Mutex
+	path       string
+	stoppedAt  time.Time
+	resumed    map[int]bool
+	Channels   map[int]*ChannelState `json:"channels"`
+	Quarantine []QuarantineEntry     `json:"quarantine"`
+	SavedAt    time.Time             `json:"saved_at"`
+}
+
+// NewStateStore loads state from path. Missing file means empty state
+func NewStateStore(path string) (*StateStore, error) {
+	s := &StateStore{
+		path:     path,
+		resumed:  make(map[int]bool),
+		Channels: make(map[int]*ChannelState),
+	}
+	body, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(body, s); err != nil {
+		return nil, err
+	}
+	if s.Channels == nil {
+		s.Channels = make(map[int]*ChannelState)
+	}
+	s.stoppedAt = s.SavedAt
+	return s, nil
+}
+
+func (s *StateStore) channel(id int) *ChannelState {
+	state, ok := s.Channels[id]
+	if !ok {
+		state = &ChannelState{}
+		s.Channels[id] = state
+	}
+	return state
+}
+
+// Play marks url as current entry of channel, played from offset
+func (s *StateStore) Play(channel int, url string, offset time.Duration) {
+	s.Lock()
+	state := s.channel(channel)
+	state.Current = url
+	state.StartedAt = time.Now().Add(-offset)
+	s.resumed[channel] = true
+	s.Unlock()
+}
+
+// Resume returns state of channel saved before restart and offset within
+// current entry. State is returned only once per channel
+func (s *StateStore) Resume(channel int) (ChannelState, time.Duration, bool) {
+	s.Lock()
+	defer s.Unlock()
+	state, ok := s.Channels[channel]
+	if !ok || s.resumed[channel] || state.Current == "" {
+		return ChannelState{}, 0, false
+	}
+	s.resumed[channel] = true
+	offset := s.stoppedAt.Sub(state.StartedAt)
+	if offset < 0 {
+		offset = 0
+	}
+	return *state, offset, true
+}
+
+// Current returns state of channel in current run
+func (s *StateStore) Current(channel int) (ChannelState, bool) {
+	s.Lock()
+	defer s.Unlock()
+	state, ok := s.Channels[channel]
+	if !ok || !s.resumed[channel] {
+		return ChannelState{}, false
+	}
+	return *state, true
+}
+
+// SetQueue stores order of channel links
+func (s *StateStore) SetQueue(channel int, queue []string) {
+	s.Lock()
+	s.channel(channel).Queue = queue
+	s.Unlock()
+}
+
+// Remove forgets state of channel
+func (s *StateStore) Remove(channel int) {
+	s.Lock()
+	delete(s.Channels, channel)
+	s.Unlock()
+}
+
+// SetQuarantine stores quarantined files
+func (s *StateStore) SetQuarantine(entries []QuarantineEntry) {
+	s.Lock()
+	s.Quarantine = entries
+	s.Unlock()
+}
+
+// QuarantineEntries returns quarantined files saved before restart
+func (s *StateStore) QuarantineEntries() []QuarantineEntry {
+	s.Lock()
+	defer s.Unlock()
+	entries := make([]QuarantineEntry, len(s.Quarantine))
+	copy(entries, s.Quarantine)
+	return entries
+}
+
+// Save writes state to disk
+func (s *StateStore) Save() error {
+	s.Lock()
+	defer s.Unlock()
+	s.SavedAt = time.Now()
+	body, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, body, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// resumeStream restores saved queue order of stream and returns entry
+// that was playing before restart with offset within it
+func (ys *YoutubeStreamService) resumeStream(item *data.StreamItem) (*list.Element, time.Duration) {
+	state, offset, ok := ys.state.Resume(item.ID)
+	if !ok {
+		return nil, 0
+	}
+	item.Lock()
+	defer item.Unlock()
+	restoreQueue(item.Links, state.Queue)
+	for e := item.Links.Front(); e != nil; e = e.Next() {
+		if fmt.Sprintf("%v", e.Value) == state.Current {
+			ys.logger.Infof("Resuming channel %s at %s from %s", item.Name, state.Current, offset)
+			return e, offset
+		}
+	}
+	return nil, 0
+}
+
+// restoreQueue moves links found in queue to front in queue order,
+// links missing in queue keep their order after them
+func restoreQueue(links *list.List, queue []string) {
+	if len(queue) == 0 {
+		return
+	}
+	present := make(map[string]*list.Element)
+	for e := links.Front(); e != nil; e = e.Next() {
+		present[fmt.Sprintf("%v", e.Value)] = e
+	}
+	var mark *list.Element
+	for _, link := range queue {
+		e, ok := present[link]
+		if !ok {
+			continue
+		}
+		delete(present, link)
+		if mark == nil {
+			links.MoveToFront(e)
+		} else {
+			links.MoveAfter(e, mark)
+		}
+		mark = e
+	}
+}
+
+// checkpoint saves queue of every stream and state to disk
+func (ys *YoutubeStreamService) checkpoint() {
+	ys.ss.RLock()
+	queues := make(map[int][]string, len(ys.ss.Items))
+	for id := range ys.ss.Items {
+		links := ys.ss.Items[id].Links
+		var queue []string
+		for e := links.Front(); e != nil; e = e.Next() {
+			queue = append(queue, fmt.Sprintf("%v", e.Value))
+		}
+		queues[id] = queue
+	}
+	ys.ss.RUnlock()
+	for id, queue := range queues {
+		ys.state.SetQueue(id, queue)
+	}
+	if err := ys.state.Save(); err != nil {
+		ys.logger.Errorf("Got error %s while saving channel state", err)
+	}
+}
+
+// Stop stops service and saves state, so playhead isn't lost on shutdown
+func (ys *YoutubeStreamService) Stop() {
+	ys.BaseService.Stop()
+	if ys.state == nil {
+		return
+	}
+	ys.checkpoint()
+	ys.logger.Info("Channel state saved")
+}
+
+func (ys *YoutubeStreamService) runCheckpoint() {
+	defer ys.s.waitGroup.Done()
+	for !ys.IsNeedStop() {
+		time.Sleep(stateCheckpointInterval)
+		ys.checkpoint()
+	}
+}
@@ -11,7 +11,8 @@ import (
 // Streamer is main struct of daemon
 // it stores all services that used by
 type Streamer struct {
-	config *conf.StreamerConfig
+	config   *conf.StreamerConfig
+	configMu sync.RWMutex
 
 	services  map[string]Service
 	waitGroup sync.WaitGroup
@@ -27,10 +28,14 @@ func NewStreamer(config *conf.StreamerConfig) *Streamer {
 	s.services = make(map[string]Service)
 	s.AddService(&YoutubeStreamService{})
 	s.AddService(&HTTPService{})
+	s.AddService(&WebSubService{})
+	s.AddService(&ConfigService{})
+	s.AddService(&WebhookService{})
 	return s
 }
 
-// Start starts all services in separate goroutine
+// Start starts all services in separate goroutine. Every service is
+// initialized before any of them runs, so services can use each other
 func (s *Streamer) Start() error {
 	s.logger.Info("Starting streamer backend")
 	for _, service := range s.services {
@@ -38,6 +43,8 @@ func (s *Streamer) Start() error {
 		if err := service.Init(s); err != nil {
 			return fmt.Errorf("initialization of %q finished with error: %v", service.Name(), err)
 		}
+	}
+	for _, service := range s.services {
 		s.waitGroup.Add(1)
 
 		go func(srv Service) {
@@ -59,9 +66,18 @@ func (s *Streamer) AddService(srv Service) {
 
 // Config returns current instance of StreamerConfig
 func (s *Streamer) Config() conf.StreamerConfig {
+	s.configMu.RLock()
+	defer s.configMu.RUnlock()
 	return *s.config
 }
 
+// SetConfig replaces current StreamerConfig
+func (s *Streamer) SetConfig(config conf.StreamerConfig) {
+	s.configMu.Lock()
+	s.config = &config
+	s.configMu.Unlock()
+}
+
 // Stop stops all services running
 func (s *Streamer) Stop() {
 	s.logger.Info("Worker is stopping...")
@@ -83,3 +99,30 @@ func (s *Streamer) YoutubeStreamService() *YoutubeStreamService {
 	}
 	return service.(*YoutubeStreamService)
 }
+
+// WebSubService returns *WebSubService
+func (s *Streamer) WebSubService() *WebSubService {
+	service, ok := s.services["websub"]
+	if !ok {
+		s.logger.Info("websub not found")
+	}
+	return service.(*WebSubService)
+}
+
+// ConfigService returns *ConfigService
+func (s *Streamer) ConfigService() *ConfigService {
+	service, ok := s.services["config_watcher"]
+	if !ok {
+		s.logger.Info("config_watcher not found")
+	}
+	return service.(*ConfigService)
+}
+
+// WebhookService returns *WebhookService
+func (s *Streamer) WebhookService() *WebhookService {
+	service, ok := s.services["webhooks"]
+	if !ok {
+		s.logger.Info("webhooks not found")
+	}
+	return service.(*WebhookService)
+}
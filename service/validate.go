@@ -0,0 +1,156 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/maddevsio/yourcast-streamer/service/data"
+	"github.com/maddevsio/yourcast-streamer/stream"
+)
+
+// slugPattern is allowed format of stream slug, slug becomes part of RTMP url
+var slugPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// allowed values of search filter fields, empty value is allowed too
+var filterValues = map[string][]string{
+	"safe_search": {"none", "moderate", "strict"},
+	"definition":  {"any", "high", "standard"},
+	"caption":     {"any", "closedCaption", "none"},
+	"license":     {"any", "creativeCommon", "youtube"},
+	"ordering":    {OrderRandom, OrderNewest, OrderMostViewed, OrderLikeRatio, OrderRoundRobin, OrderWeighted},
+}
+
+// FieldError describes invalid field of request
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every invalid field of stream definition
+type ValidationError []FieldError
+
+func (v ValidationError) Error() string {
+	messages := make([]string, 0, len(v))
+	for _, fe := range v {
+		messages = append(messages, fe.Field+": "+fe.Message)
+	}
+	return strings.Join(messages, ", ")
+}
+
+func (v *ValidationError) add(field, format string, args ...interface{}) {
+	*v = append(*v, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// ValidateStream checks stream definition before it's applied. Autostreams
+// need positive update frequency, links must be handled by one of sources
+func ValidateStream(stream data.Stream) error {
+	var v ValidationError
+	if stream.ID <= 0 {
+		v.add("id", "must be positive")
+	}
+	if strings.TrimSpace(stream.Name) == "" {
+		v.add("name", "must not be empty")
+	}
+	if stream.Slug == "" {
+		v.add("slug", "must not be empty")
+	} else if !slugPattern.MatchString(stream.Slug) {
+		v.add("slug", "must contain only letters, digits, - and _")
+	}
+	if stream.Logo != "" && !isHTTPURL(stream.Logo) {
+		v.add("logo", "must be http or https url")
+	}
+	validateLinks(&v, "links", stream.Links)
+	validateLinks(&v, "filler", stream.Filler)
+
+	switch {
+	case stream.IsAutoStream() && stream.UpdateFrequency <= 0:
+		v.add("update_frequency", "must be positive for autostream")
+	case stream.UpdateFrequency < 0:
+		v.add("update_frequency", "must not be negative")
+	}
+	for name, value := range map[string]int{
+		"max_results":       stream.MaxResults,
+		"lookback_hours":    stream.LookbackHours,
+		"min_duration":      stream.MinDuration,
+		"max_duration":      stream.MaxDuration,
+		"no_repeat_minutes": stream.NoRepeatMinutes,
+		"no_repeat_count":   stream.NoRepeatCount,
+		"video_length":      stream.VideoLength,
+	} {
+		if value < 0 {
+			v.add(name, "must not be negative")
+		}
+	}
+	if stream.MinDuration > 0 && stream.MaxDuration > 0 && stream.MinDuration > stream.MaxDuration {
+		v.add("max_duration", "must not be less than min_duration")
+	}
+	for name, value := range map[string]string{
+		"safe_search": stream.SafeSearch,
+		"definition":  stream.Definition,
+		"caption":     stream.Caption,
+		"license":     stream.License,
+		"ordering":    stream.Ordering,
+	} {
+		if value != "" && !contains(filterValues[name], value) {
+			v.add(name, "must be one of %s", strings.Join(filterValues[name], ", "))
+		}
+	}
+	for source, weight := range stream.Weights {
+		if weight < 0 {
+			v.add("weights."+source, "must not be negative")
+		}
+	}
+	if err := validateSchedule(stream); err != nil {
+		v.add("schedule", "%s", err)
+	}
+	for i, block := range stream.Schedule {
+		validateLinks(&v, fmt.Sprintf("schedule.%d.content.links", i), block.Content.Links)
+	}
+	if len(v) == 0 {
+		return nil
+	}
+	sortFieldErrors(v)
+	return v
+}
+
+func validateLinks(v *ValidationError, field string, links []data.StreamLink) {
+	for i, link := range links {
+		if !isSourceLink(link.URL) {
+			v.add(fmt.Sprintf("%s.%d.url", field, i), "must be link supported by one of sources")
+		}
+	}
+}
+
+// isSourceLink returns true if link is absolute and some source can handle it
+func isSourceLink(value string) bool {
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" && u.Path == "" {
+		return false
+	}
+	_, err = stream.SourceFor(value)
+	return err == nil
+}
+
+func isHTTPURL(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// sortFieldErrors orders errors by field so response doesn't depend on map order
+func sortFieldErrors(v ValidationError) {
+	sort.SliceStable(v, func(i, j int) bool {
+		return v[i].Field < v[j].Field
+	})
+}
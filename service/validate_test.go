@@ -0,0 +1,42 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/maddevsio/yourcast-streamer/service/data"
+)
+
+func TestValidateStreamLinks(t *testing.T) {
+	stream := data.Stream{
+		ID:   1,
+		Name: "local",
+		Slug: "local",
+		Links: []data.StreamLink{
+			{URL: "file:///media/library/episode.mp4"},
+			{URL: "https://www.youtube.com/watch?v=video1"},
+			{URL: "http://cdn.example/video.mp4"},
+		},
+		Filler: []data.StreamLink{{URL: "file:///media/filler/ident.mp4"}},
+		Schedule: []data.ScheduleBlock{{
+			Start:    "20:00",
+			Duration: 60,
+			Content:  data.Stream{Links: []data.StreamLink{{URL: "file:///media/movies/movie.mp4"}}},
+		}},
+	}
+	if err := ValidateStream(stream); err != nil {
+		t.Fatalf("links handled by sources must be valid, got %s", err)
+	}
+
+	stream.Links = []data.StreamLink{{URL: "ftp://example.com/video.mp4"}, {URL: "video.mp4"}, {URL: "http://"}}
+	err := ValidateStream(stream)
+	fields, ok := err.(ValidationError)
+	if !ok || len(fields) != 3 {
+		t.Fatalf("expected 3 invalid links, got %v", err)
+	}
+	for i, fe := range fields {
+		if fe.Field != fmt.Sprintf("links.%d.url", i) {
+			t.Fatalf("unexpected field %s", fe.Field)
+		}
+	}
+}
@@ -0,0 +1,335 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gen1us2k/log"
+)
+
+// Webhook events
+const (
+	EventItemStarted         = "item.started"
+	EventItemFinished        = "item.finished"
+	EventItemFailed          = "item.failed"
+	EventItemQuarantined     = "item.quarantined"
+	EventDownloadCompleted   = "download.completed"
+	EventDownloadFailed      = "download.failed"
+	EventChannelIdle         = "channel.idle"
+	EventAutostreamRefreshed = "autostream.refreshed"
+)
+
+// maxWebhookAttempts limits how many times delivery is tried before it's dropped
+const maxWebhookAttempts = 10
+
+// webhookBackoff is delay before first retry, it doubles with every attempt
+// up to maxWebhookBackoff
+const (
+	webhookBackoff    = 10 * time.Second
+	maxWebhookBackoff = time.Hour
+)
+
+// webhookTimeout limits duration of single delivery
+const webhookTimeout = 10 * time.Second
+
+// maxDuePerURL limits how many due webhooks are delivered to url at once
+const maxDuePerURL = 20
+
+// maxWebhookQueue limits size of retry queue, oldest deliveries are dropped
+const maxWebhookQueue = 1000
+
+// WebhookEvent is JSON body of webhook
+type WebhookEvent struct {
+	ID    string      `json:"id"`
+	Event string      `json:"event"`
+	Time  time.Time   `json:"time"`
+	Data  interface{} `json:"data"`
+}
+
+// ItemEvent is data of item and download events
+type ItemEvent struct {
+	ChannelID int    `json:"channel_id,omitempty"`
+	Channel   string `json:"channel,omitempty"`
+	URL       string `json:"url"`
+	File      string `json:"file,omitempty"`
+	Attempt   int    `json:"attempt,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ChannelEvent is data of channel events
+type ChannelEvent struct {
+	ChannelID int    `json:"channel_id"`
+	Channel   string `json:"channel"`
+	Links     int    `json:"links"`
+}
+
+// Delivery is webhook waiting in retry queue
+type Delivery struct {
+	ID          string          `json:"id"`
+	URL         string          `json:"url"`
+	Event       string          `json:"event"`
+	Body        json.RawMessage `json:"body"`
+	Attempts    int             `json:"attempts"`
+	NextAttempt time.Time       `json:"next_attempt"`
+	LastError   string          `json:"last_error,omitempty"`
+}
+
+// WebhookService delivers events to configured urls as HMAC signed JSON
+// POST requests. Every url is delivered to independently, failed deliveries
+// are retried with backoff, the queue is persisted on disk and survives restarts
+type WebhookService struct {
+	BaseService
+
+	s      *Streamer
+	client *http.Client
+
+	mu    sync.Mutex
+	path  string
+	queue []*Delivery
+	busy  map[string]bool
+	wake  chan struct{}
+
+	logger log.Logger
+}
+
+// Name returns name of service
+func (wh *WebhookService) Name() string {
+	return "webhooks"
+}
+
+// Init initializes logger and loads retry queue
+func (wh *WebhookService) Init(s *Streamer) error {
+	wh.s = s
+	wh.logger = log.NewLogger(wh.Name())
+	wh.client = &http.Client{Timeout: webhookTimeout}
+	wh.wake = make(chan struct{}, 1)
+	wh.busy = make(map[string]bool)
+	wh.path = filepath.Join(s.Config().RootPath, "webhooks.json")
+	body, err := ioutil.ReadFile(wh.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, &wh.queue)
+}
+
+// Run delivers queued webhooks when they are due
+func (wh *WebhookService) Run() error {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for !wh.IsNeedStop() {
+		wh.deliverDue()
+		select {
+		case <-ticker.C:
+		case <-wh.wake:
+		}
+	}
+	return nil
+}
+
+// Emit queues event for every configured url that subscribed to it.
+// Nothing is queued without secret, config validation requires it
+func (wh *WebhookService) Emit(event string, data interface{}) {
+	config := wh.s.Config()
+	urls := splitList(config.WebhookURLs)
+	if len(urls) == 0 || config.WebhookSecret == "" || !wantsEvent(config.WebhookEvents, event) {
+		return
+	}
+	id := deliveryID()
+	body, err := json.Marshal(WebhookEvent{ID: id, Event: event, Time: time.Now(), Data: data})
+	if err != nil {
+		wh.logger.Errorf("Got error %s while encoding %s webhook", err, event)
+		return
+	}
+	wh.mu.Lock()
+	for i, url := range urls {
+		wh.queue = append(wh.queue, &Delivery{
+			ID:          fmt.Sprintf("%s-%d", id, i),
+			URL:         url,
+			Event:       event,
+			Body:        body,
+			NextAttempt: time.Now(),
+		})
+	}
+	if dropped := len(wh.queue) - maxWebhookQueue; dropped > 0 {
+		wh.logger.Errorf("Webhook queue is full, dropping %d oldest deliveries", dropped)
+		wh.queue = wh.queue[dropped:]
+	}
+	wh.save()
+	wh.mu.Unlock()
+	select {
+	case wh.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Pending returns deliveries waiting in retry queue
+func (wh *WebhookService) Pending() []Delivery {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	pending := make([]Delivery, 0, len(wh.queue))
+	for _, d := range wh.queue {
+		pending = append(pending, *d)
+	}
+	return pending
+}
+
+// deliverDue starts delivery of due webhooks for every url that is not
+// being delivered to already
+func (wh *WebhookService) deliverDue() {
+	now := time.Now()
+	wh.mu.Lock()
+	due := make(map[string][]*Delivery)
+	for _, d := range wh.queue {
+		if !wh.busy[d.URL] && !d.NextAttempt.After(now) && len(due[d.URL]) < maxDuePerURL {
+			due[d.URL] = append(due[d.URL], d)
+		}
+	}
+	for url := range due {
+		wh.busy[url] = true
+	}
+	wh.mu.Unlock()
+	for url, deliveries := range due {
+		go wh.deliverURL(url, deliveries)
+	}
+}
+
+// deliverURL delivers webhooks to url in order. Deliveries after failed one
+// wait for next round, so dead endpoint doesn't spend timeout on each of them
+func (wh *WebhookService) deliverURL(url string, deliveries []*Delivery) {
+	for _, d := range deliveries {
+		err := wh.deliver(d)
+		wh.mu.Lock()
+		d.Attempts++
+		switch {
+		case err == nil:
+			wh.remove(d)
+		case d.Attempts >= maxWebhookAttempts:
+			wh.logger.Errorf("Dropping %s webhook to %s after %d attempts, %v", d.Event, d.URL, d.Attempts, err)
+			wh.remove(d)
+		default:
+			wh.logger.Errorf("Got error %s while delivering %s webhook to %s, retrying", err, d.Event, d.URL)
+			d.LastError = err.Error()
+			d.NextAttempt = time.Now().Add(backoff(d.Attempts))
+		}
+		wh.mu.Unlock()
+		if err != nil {
+			break
+		}
+	}
+	wh.mu.Lock()
+	delete(wh.busy, url)
+	wh.save()
+	wh.mu.Unlock()
+}
+
+// remove removes delivery from queue, caller holds lock
+func (wh *WebhookService) remove(delivery *Delivery) {
+	for i, d := range wh.queue {
+		if d == delivery {
+			wh.queue = append(wh.queue[:i], wh.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+func (wh *WebhookService) deliver(d *Delivery) error {
+	req, err := http.NewRequest(http.MethodPost, d.URL, bytes.NewReader(d.Body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Yourcast-Event", d.Event)
+	req.Header.Set("X-Yourcast-Delivery", d.ID)
+	// signature covers timestamp, so captured delivery can't be replayed later
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("X-Yourcast-Timestamp", timestamp)
+	req.Header.Set("X-Yourcast-Signature", "sha256="+sign(wh.s.Config().WebhookSecret, []byte(timestamp+"\n"+string(d.Body))))
+	resp, err := wh.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// save writes retry queue to disk, caller holds lock
+func (wh *WebhookService) save() {
+	body, err := json.Marshal(wh.queue)
+	if err == nil {
+		tmp := wh.path + ".tmp"
+		if err = ioutil.WriteFile(tmp, body, 0644); err == nil {
+			err = os.Rename(tmp, wh.path)
+		}
+	}
+	if err != nil {
+		wh.logger.Errorf("Got error %s while saving webhook queue", err)
+	}
+}
+
+// sign returns hex encoded HMAC-SHA256 of body
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func backoff(attempts int) time.Duration {
+	delay := webhookBackoff
+	for i := 1; i < attempts && delay < maxWebhookBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxWebhookBackoff {
+		delay = maxWebhookBackoff
+	}
+	return delay
+}
+
+func deliveryID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// wantsEvent returns true if event matches comma separated list of events.
+// Empty list matches every event, prefix like item.* matches group of events
+func wantsEvent(events, event string) bool {
+	list := splitList(events)
+	if len(list) == 0 {
+		return true
+	}
+	for _, e := range list {
+		if e == event || (strings.HasSuffix(e, ".*") && strings.HasPrefix(event, strings.TrimSuffix(e, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,295 @@
+package service
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gen1us2k/log"
+	"github.com/maddevsio/yourcast-streamer/bot"
+)
+
+// renewBefore is how long before lease expiration subscription is renewed
+const renewBefore = time.Hour
+
+// resubscribeAfter is how long unverified subscription waits before retry
+const resubscribeAfter = 10 * time.Minute
+
+// newUploadWindow is how long after publishing pushed video is treated as
+// new upload, hub also notifies about edits of old videos
+const newUploadWindow = 6 * time.Hour
+
+// editTolerance is how much later than published video may be updated to
+// be treated as new upload, larger gap means notification is about edit
+const editTolerance = 15 * time.Minute
+
+// Subscription describes WebSub subscription to channel uploads
+type Subscription struct {
+	ChannelID   string    `json:"channel_id"`
+	Streams     []int     `json:"streams"`
+	Verified    bool      `json:"verified"`
+	RequestedAt time.Time `json:"requested_at"`
+	Expires     time.Time `json:"expires"`
+}
+
+type subscription struct {
+	streams     map[int]bool
+	verified    bool
+	requestedAt time.Time
+	expires     time.Time
+}
+
+// WebSubService subscribes to uploads of channels followed by news
+// autostreams and pushes new videos into them as soon as hub notifies
+type WebSubService struct {
+	BaseService
+
+	s      *Streamer
+	ys     *YoutubeStreamService
+	client *bot.WebSubClient
+	secret string
+
+	mu            sync.Mutex
+	subscriptions map[string]*subscription
+	unsubscribe   []string
+	wake          chan struct{}
+
+	logger log.Logger
+}
+
+// Name returns name of service
+func (ws *WebSubService) Name() string {
+	return "websub"
+}
+
+// Init initializes logger and WebSub client
+func (ws *WebSubService) Init(s *Streamer) error {
+	ws.s = s
+	ws.logger = log.NewLogger(ws.Name())
+	ws.ys = s.YoutubeStreamService()
+	ws.secret = s.Config().WebSubSecret
+	if ws.secret == "" {
+		ws.secret = randomSecret()
+	}
+	ws.client = bot.NewWebSubClient(
+		s.Config().WebSubHubURL,
+		s.Config().WebSubCallbackURL,
+		ws.secret,
+		s.Config().WebSubLeaseSeconds,
+	)
+	ws.init()
+	return nil
+}
+
+func (ws *WebSubService) init() {
+	ws.mu.Lock()
+	if ws.subscriptions == nil {
+		ws.subscriptions = make(map[string]*subscription)
+		ws.wake = make(chan struct{}, 1)
+	}
+	ws.mu.Unlock()
+}
+
+// randomSecret returns secret used for subscriptions when it's not configured
+func randomSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Enabled returns true if callback url is configured
+func (ws *WebSubService) Enabled() bool {
+	return ws.s.Config().WebSubCallbackURL != ""
+}
+
+// Run subscribes to followed channels and renews leases
+func (ws *WebSubService) Run() error {
+	if !ws.Enabled() {
+		ws.logger.Info("WebSub callback url is not set, push notifications are disabled")
+		return nil
+	}
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for !ws.IsNeedStop() {
+		ws.renew()
+		select {
+		case <-ticker.C:
+		case <-ws.wake:
+		}
+	}
+	return nil
+}
+
+// renew sends subscribe requests for new and expiring subscriptions and
+// unsubscribe requests for channels nobody follows anymore
+func (ws *WebSubService) renew() {
+	now := time.Now()
+	ws.mu.Lock()
+	var subscribe []string
+	for channelID, sub := range ws.subscriptions {
+		expiring := sub.verified && now.Add(renewBefore).After(sub.expires)
+		pending := !sub.verified && now.Sub(sub.requestedAt) > resubscribeAfter
+		if expiring || pending {
+			sub.requestedAt = now
+			subscribe = append(subscribe, channelID)
+		}
+	}
+	unsubscribe := ws.unsubscribe
+	ws.unsubscribe = nil
+	ws.mu.Unlock()
+
+	for _, channelID := range subscribe {
+		ws.logger.Infof("Subscribing to uploads of %s", channelID)
+		if err := ws.client.Subscribe(channelID); err != nil {
+			ws.logger.Errorf("Error while subscribing to %s, %v", channelID, err)
+		}
+	}
+	for _, channelID := range unsubscribe {
+		ws.logger.Infof("Unsubscribing from uploads of %s", channelID)
+		if err := ws.client.Unsubscribe(channelID); err != nil {
+			ws.logger.Errorf("Error while unsubscribing from %s, %v", channelID, err)
+		}
+	}
+}
+
+// SetStreamChannels sets channels followed by stream
+func (ws *WebSubService) SetStreamChannels(streamID int, channelIDs []string) {
+	ws.init()
+	follow := make(map[string]bool)
+	for _, channelID := range channelIDs {
+		follow[channelID] = true
+	}
+	ws.mu.Lock()
+	changed := false
+	for channelID, sub := range ws.subscriptions {
+		if follow[channelID] || !sub.streams[streamID] {
+			continue
+		}
+		delete(sub.streams, streamID)
+		if len(sub.streams) == 0 {
+			delete(ws.subscriptions, channelID)
+			ws.unsubscribe = append(ws.unsubscribe, channelID)
+			changed = true
+		}
+	}
+	for channelID := range follow {
+		sub, ok := ws.subscriptions[channelID]
+		if !ok {
+			sub = &subscription{streams: make(map[int]bool)}
+			ws.subscriptions[channelID] = sub
+			changed = true
+		}
+		sub.streams[streamID] = true
+	}
+	ws.mu.Unlock()
+	if changed {
+		select {
+		case ws.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Verify handles verification request of hub. It returns challenge and true
+// if request matches state of subscription
+func (ws *WebSubService) Verify(mode, topic, challenge string, leaseSeconds int) (string, bool) {
+	ws.init()
+	channelID := bot.ChannelByTopic(topic)
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	sub, ok := ws.subscriptions[channelID]
+	switch mode {
+	case "subscribe":
+		if !ok {
+			return "", false
+		}
+		sub.verified = true
+		sub.expires = time.Now().Add(time.Duration(leaseSeconds) * time.Second)
+		ws.logger.Infof("Subscription to %s verified for %d seconds", channelID, leaseSeconds)
+		return challenge, true
+	case "unsubscribe":
+		if ok {
+			return "", false
+		}
+		return challenge, true
+	case "denied":
+		if ok {
+			sub.verified = false
+		}
+		ws.logger.Errorf("Subscription to %s denied by hub", channelID)
+		return "", true
+	}
+	return "", false
+}
+
+// Notify handles notification about new uploads. Videos are pushed into
+// streams that follow channel. Notifications with invalid signature, about
+// edits of old videos and videos that stream already has are ignored
+func (ws *WebSubService) Notify(body []byte, signature string) {
+	if !bot.VerifySignature(ws.secret, body, signature) {
+		ws.logger.Error("Ignoring notification with invalid signature")
+		return
+	}
+	entries, err := bot.ParseFeed(bytes.NewReader(body))
+	if err != nil {
+		ws.logger.Errorf("Error while parsing notification, %v", err)
+		return
+	}
+	now := time.Now()
+	for _, entry := range entries {
+		if !isNewUpload(entry, now) {
+			ws.logger.Debugf("Ignoring notification about %s, video is not a new upload", entry.VideoID)
+			continue
+		}
+		ws.mu.Lock()
+		var streams []int
+		if sub, ok := ws.subscriptions[entry.ChannelID]; ok {
+			for streamID := range sub.streams {
+				streams = append(streams, streamID)
+			}
+		}
+		ws.mu.Unlock()
+		for _, streamID := range streams {
+			if ws.ys.PushLink(streamID, videoURL(entry.VideoID)) {
+				ws.logger.Infof("New video %s on %s, pushed to stream %d", entry.VideoID, entry.ChannelID, streamID)
+			}
+		}
+	}
+}
+
+// isNewUpload returns true if entry is recently published video that was
+// not edited since
+func isNewUpload(entry bot.FeedEntry, now time.Time) bool {
+	if entry.Published.IsZero() || now.Sub(entry.Published) > newUploadWindow {
+		return false
+	}
+	return entry.Updated.IsZero() || entry.Updated.Sub(entry.Published) <= editTolerance
+}
+
+// Subscriptions returns state of every subscription
+func (ws *WebSubService) Subscriptions() []Subscription {
+	ws.init()
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	subscriptions := make([]Subscription, 0, len(ws.subscriptions))
+	for channelID, sub := range ws.subscriptions {
+		s := Subscription{
+			ChannelID:   channelID,
+			Verified:    sub.verified,
+			RequestedAt: sub.requestedAt,
+			Expires:     sub.expires,
+		}
+		for streamID := range sub.streams {
+			s.Streams = append(s.Streams, streamID)
+		}
+		sort.Ints(s.Streams)
+		subscriptions = append(subscriptions, s)
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptions[i].ChannelID < subscriptions[j].ChannelID
+	})
+	return subscriptions
+}
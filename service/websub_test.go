@@ -0,0 +1,172 @@
+package service
+
+import (
+	"container/list"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gen1us2k/log"
+	"github.com/maddevsio/yourcast-streamer/conf"
+	"github.com/maddevsio/yourcast-streamer/service/data"
+)
+
+const testChannelID = "UCabcdefghijklmnopqrstuv"
+
+// hubStandIn records subscription requests like WebSub hub does
+type hubStandIn struct {
+	sync.Mutex
+	requests []url.Values
+}
+
+func (h *hubStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	h.Lock()
+	h.requests = append(h.requests, r.PostForm)
+	h.Unlock()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func newTestWebSub(t *testing.T, hubURL, secret string) (*WebSubService, *YoutubeStreamService) {
+	dir, err := ioutil.TempDir("", "websub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	history, err := NewPlayHistory(filepath.Join(dir, "history.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Streamer{config: &conf.StreamerConfig{
+		WebSubHubURL:      hubURL,
+		WebSubCallbackURL: "http://streamer.example/websub",
+		WebSubSecret:      secret,
+	}}
+	ys := &YoutubeStreamService{
+		s:        s,
+		ss:       data.NewStreamStorage(),
+		history:  history,
+		breaking: make(map[int][]string),
+		logger:   log.NewLogger("test"),
+	}
+	ys.ss.Items[1] = data.StreamItem{ID: 1, Name: "news", Links: list.New()}
+	s.services = map[string]Service{"youtube_stream_service": ys}
+	ws := &WebSubService{}
+	if err := ws.Init(s); err != nil {
+		t.Fatal(err)
+	}
+	return ws, ys
+}
+
+func notification(published, updated time.Time, videoID string) []byte {
+	return []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns:yt="http://www.youtube.com/xml/schemas/2015" xmlns="http://www.w3.org/2005/Atom">
+ <entry>
+  <yt:videoId>%s</yt:videoId>
+  <yt:channelId>%s</yt:channelId>
+  <title>video</title>
+  <published>%s</published>
+  <updated>%s</updated>
+ </entry>
+</feed>`, videoID, testChannelID, published.Format(time.RFC3339), updated.Format(time.RFC3339)))
+}
+
+func signBody(secret string, body []byte) string {
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write(body)
+	return "sha1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func breakingLinks(ys *YoutubeStreamService) []string {
+	ys.breakingMu.Lock()
+	defer ys.breakingMu.Unlock()
+	return append([]string(nil), ys.breaking[1]...)
+}
+
+func TestWebSubSubscribeAndNotify(t *testing.T) {
+	hub := &hubStandIn{}
+	server := httptest.NewServer(hub)
+	defer server.Close()
+	ws, ys := newTestWebSub(t, server.URL, "")
+
+	ws.SetStreamChannels(1, []string{testChannelID})
+	ws.renew()
+	if len(hub.requests) != 1 {
+		t.Fatalf("expected 1 request to hub, got %d", len(hub.requests))
+	}
+	form := hub.requests[0]
+	if form.Get("hub.mode") != "subscribe" || form.Get("hub.topic") != "https://www.youtube.com/xml/feeds/videos.xml?channel_id="+testChannelID {
+		t.Fatalf("unexpected subscription request %v", form)
+	}
+	secret := form.Get("hub.secret")
+	if secret == "" {
+		t.Fatal("subscription must carry secret when it's not configured")
+	}
+	if challenge, ok := ws.Verify("subscribe", form.Get("hub.topic"), "challenge", 3600); !ok || challenge != "challenge" {
+		t.Fatalf("verification failed: %q, %v", challenge, ok)
+	}
+
+	now := time.Now().UTC()
+	body := notification(now, now, "new")
+	ws.Notify(body, "")
+	ws.Notify(body, signBody("wrong", body))
+	if links := breakingLinks(ys); len(links) != 0 {
+		t.Fatalf("unsigned notifications must be ignored, got %v", links)
+	}
+
+	ws.Notify(body, signBody(secret, body))
+	ws.Notify(body, signBody(secret, body))
+	links := breakingLinks(ys)
+	if len(links) != 1 || links[0] != videoURL("new") {
+		t.Fatalf("expected new video pushed once, got %v", links)
+	}
+
+	edited := notification(now.Add(-30*24*time.Hour), now, "old")
+	ws.Notify(edited, signBody(secret, edited))
+	retitled := notification(now.Add(-time.Hour), now, "retitled")
+	ws.Notify(retitled, signBody(secret, retitled))
+	if links := breakingLinks(ys); len(links) != 1 {
+		t.Fatalf("edits of videos must be ignored, got %v", links)
+	}
+
+	if err := ys.history.Record(1, videoURL("aired")); err != nil {
+		t.Fatal(err)
+	}
+	aired := notification(now, now, "aired")
+	ws.Notify(aired, signBody(secret, aired))
+	if links := breakingLinks(ys); len(links) != 1 {
+		t.Fatalf("aired videos must be ignored, got %v", links)
+	}
+}
+
+func TestWebSubConfiguredSecret(t *testing.T) {
+	hub := &hubStandIn{}
+	server := httptest.NewServer(hub)
+	defer server.Close()
+	ws, ys := newTestWebSub(t, server.URL, "configured")
+
+	ws.SetStreamChannels(1, []string{testChannelID})
+	ws.renew()
+	if len(hub.requests) != 1 || hub.requests[0].Get("hub.secret") != "configured" {
+		t.Fatalf("subscription must carry configured secret, got %v", hub.requests)
+	}
+	now := time.Now().UTC()
+	body := notification(now, now, "new")
+	ws.Notify(body, signBody("configured", body))
+	if links := breakingLinks(ys); len(links) != 1 {
+		t.Fatalf("expected new video pushed, got %v", links)
+	}
+}
@@ -6,8 +6,12 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
+	"sync"
 	"time"
 
 	"google.golang.org/api/youtube/v3"
@@ -18,6 +22,9 @@ import (
 	"github.com/maddevsio/yourcast-streamer/stream"
 )
 
+// webUITimeout limits duration of request to Web UI
+const webUITimeout = 30 * time.Second
+
 // YoutubeStreamService re-streams youtube video
 // on the fly to rtmp server
 type YoutubeStreamService struct {
@@ -27,6 +34,36 @@ type YoutubeStreamService struct {
 	ss *data.StreamStorage
 	yc *bot.YoutubeClient
 
+	feeds *bot.FeedClient
+	webUI *http.Client
+
+	quarantine  *QuarantineLog
+	redownloads chan downloadJob
+
+	librariesMu sync.Mutex
+	libraries   map[int]data.Stream
+
+	history    *PlayHistory
+	state      *StateStore
+	media      *MediaIndex
+	playing    *NowPlayingHub
+	collisions *CollisionGuard
+
+	breakingMu sync.Mutex
+	breaking   map[int][]string
+
+	liveMu sync.Mutex
+	live   map[string]bool
+
+	streamsMu   sync.Mutex
+	definitions map[int]data.Stream
+	origins     map[int]string
+	stops       map[int]chan struct{}
+	refreshes   map[int]chan struct{}
+
+	reconcileMu sync.Mutex
+	reconciled  *ReconcileReport
+
 	logger log.Logger
 }
 
@@ -39,25 +76,70 @@ func (ys *YoutubeStreamService) Name() string {
 func (ys *YoutubeStreamService) Init(s *Streamer) error {
 	ys.s = s
 	ys.logger = log.NewLogger(ys.Name())
-	yc, err := bot.NewYoutubeClient(ys.s.Config().YoutubeAPIKey)
+	yc, err := bot.NewYoutubeClient(
+		strings.Split(ys.s.Config().YoutubeAPIKey, ","),
+		ys.s.Config().YoutubeDailyQuota,
+		time.Duration(ys.s.Config().YoutubeCacheTTL)*time.Second,
+	)
+	if err != nil {
+		return err
+	}
+	resolver, err := stream.NewResolver(ys.s.Config().Resolver, ys.s.Config().ExtractorPath)
 	if err != nil {
 		return err
 	}
+	stream.RegisterSource(&stream.YoutubeSource{Resolver: resolver})
 	ys.ss = data.NewStreamStorage()
 	ys.yc = yc
+	ys.feeds = bot.NewFeedClient(ys.s.Config().YoutubeFeedURL)
+	ys.webUI = &http.Client{Timeout: webUITimeout}
+	ys.redownloads = make(chan downloadJob, 100)
+	ys.libraries = make(map[int]data.Stream)
+	ys.breaking = make(map[int][]string)
+	ys.live = make(map[string]bool)
+	ys.definitions = make(map[int]data.Stream)
+	ys.origins = make(map[int]string)
+	ys.stops = make(map[int]chan struct{})
+	ys.refreshes = make(map[int]chan struct{})
+	ys.collisions = NewCollisionGuard(time.Duration(ys.s.Config().CollisionWindow) * time.Second)
+	ys.history, err = NewPlayHistory(filepath.Join(ys.s.Config().RootPath, "history.json"))
+	if err != nil {
+		return err
+	}
+	ys.state, err = NewStateStore(filepath.Join(ys.s.Config().RootPath, "state.json"))
+	if err != nil {
+		return err
+	}
+	ys.quarantine = &QuarantineLog{entries: ys.state.QuarantineEntries()}
+	ys.playing = NewNowPlayingHub()
+	ys.media, err = NewMediaIndex(filepath.Join(ys.s.Config().RootPath, "media.json"))
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
 // Run runs YoutubeStreamService
 func (ys *YoutubeStreamService) Run() error {
 	ys.logger.Info("Getting current streams")
-	streams, err := ys.getStreams()
+	streams, origins, err := ys.loadStreams()
 	if err != nil {
 		return err
 	}
 	ys.logger.Info("Streams received. Populating internal storage")
-	for _, stream := range streams {
-		if stream.IsAutoStream() {
+	ys.s.waitGroup.Add(1)
+	go ys.runRedownloads()
+	ys.s.waitGroup.Add(1)
+	go ys.runReconcile()
+	ys.s.waitGroup.Add(1)
+	go ys.runCheckpoint()
+	ys.s.waitGroup.Add(1)
+	go ys.runIndexMedia()
+	for _, stream := range ys.validStreams(streams) {
+		ys.setDefinition(stream, origins[stream.ID])
+		if stream.IsLibraryStream() {
+			stream = ys.watchLibraryStream(stream)
+		} else if stream.IsAutoStream() {
 			ys.runJobsForAutoStream(stream, false)
 			continue
 		}
@@ -70,7 +152,7 @@ func (ys *YoutubeStreamService) Run() error {
 		ys.logger.Infof("Starting streaming of %s", item.Name)
 		if !ys.s.Config().DisableStreaming {
 			ys.s.waitGroup.Add(1)
-			go ys.runStream(item)
+			go ys.runStream(item, ys.streamStop(item.ID))
 		}
 		if !item.IsAuto {
 			ys.s.waitGroup.Add(1)
@@ -82,11 +164,14 @@ func (ys *YoutubeStreamService) Run() error {
 
 func (ys *YoutubeStreamService) getStreams() ([]data.Stream, error) {
 	url := fmt.Sprintf("%s/api/streams/", ys.s.Config().WebUIURL)
-	resp, err := http.Get(url)
+	resp, err := ys.webUI.Get(url)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
@@ -99,42 +184,72 @@ func (ys *YoutubeStreamService) getStreams() ([]data.Stream, error) {
 	return streams, nil
 }
 
-func (ys *YoutubeStreamService) runStream(data data.StreamItem) {
-	data.Lock()
-	e := data.Links.Front()
-	data.Unlock()
+// streamStop returns channel that stops streaming of stream, streaming
+// started before for the same stream is stopped
+func (ys *YoutubeStreamService) streamStop(id int) <-chan struct{} {
+	return ys.replaceStop(ys.stops, id)
+}
+
+// refreshStop returns channel that stops refresh of autostream, refresh
+// started before for the same stream is stopped
+func (ys *YoutubeStreamService) refreshStop(id int) <-chan struct{} {
+	return ys.replaceStop(ys.refreshes, id)
+}
+
+func (ys *YoutubeStreamService) replaceStop(stops map[int]chan struct{}, id int) <-chan struct{} {
+	stop := make(chan struct{})
+	ys.streamsMu.Lock()
+	if running, ok := stops[id]; ok {
+		close(running)
+	}
+	stops[id] = stop
+	ys.streamsMu.Unlock()
+	return stop
+}
+
+func (ys *YoutubeStreamService) runStream(data data.StreamItem, stop <-chan struct{}) {
+	e, offset := ys.resumeStream(&data)
+	if e == nil {
+		data.Lock()
+		e = data.Links.Front()
+		data.Unlock()
+	}
 	ys.logger.Infof("Preparing to stream items in %s channel", data.Name)
-	for {
+	deferred := 0
+	idle := false
+	for !stopped(stop) {
+		if link, ok := ys.nextBreaking(data.ID); ok {
+			ys.collisions.Claim(data.ID, link, true)
+			ys.playLink(&data, link, 0, 0)
+			continue
+		}
+		if ys.playScheduled(&data, stop) {
+			continue
+		}
 		if e == nil {
+			if !idle {
+				ys.logger.Infof("Channel %s has nothing to stream", data.Name)
+				ys.emit(EventChannelIdle, ChannelEvent{ChannelID: data.ID, Channel: data.Name})
+				idle = true
+			}
+			time.Sleep(time.Second)
+			data.Lock()
+			e = data.Links.Front()
+			data.Unlock()
 			continue
 		}
+		idle = false
 		youtubeURL := fmt.Sprintf("%v", e.Value)
-		absFileName := stream.GetFileNameByURL(youtubeURL, ys.s.Config().RootPath)
-		dstURL := fmt.Sprintf("%s/%s", ys.s.Config().RTMPRootServerURL, data.Slug)
-		if _, err := os.Stat(absFileName); err == nil {
-			ys.logger.Infof(
-				"Streaming channel %s video %s from file",
-				data.Name, youtubeURL,
-			)
-			err := stream.FromLocalFile(
-				ys.s.Config().FFMpegPath,
-				absFileName, dstURL,
-			)
-			if err != nil {
-				ys.logger.Errorf("Got error %s while streaming video %s for channel %s", err, youtubeURL, data.Name)
-			}
+		data.RLock()
+		total := data.Links.Len()
+		data.RUnlock()
+		if ys.collisions.Claim(data.ID, youtubeURL, deferred >= total) {
+			deferred = 0
+			ys.playLink(&data, youtubeURL, offset, ys.untilNextAiring(data.ID))
+			offset = 0
 		} else {
-			ys.logger.Infof(
-				"Streaming channel %s video %s from Youtube",
-				data.Name, youtubeURL,
-			)
-			err := stream.FromYoutubeURL(
-				ys.s.Config().FFMpegPath,
-				youtubeURL, dstURL, ys.s.Config().RootPath,
-			)
-			if err != nil {
-				ys.logger.Errorf("Got error %s while streaming video %s for channel %s", err, youtubeURL, data.Name)
-			}
+			ys.logger.Infof("Deferring video %s on channel %s, it is airing on another channel", youtubeURL, data.Name)
+			deferred++
 		}
 		data.Lock()
 		e = e.Next()
@@ -147,23 +262,264 @@ func (ys *YoutubeStreamService) runStream(data data.StreamItem) {
 	ys.s.waitGroup.Done()
 }
 
+func stopped(stop <-chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// sleepUnlessStopped sleeps for d and returns false if stream is stopped meanwhile
+func sleepUnlessStopped(stop <-chan struct{}, d time.Duration) bool {
+	select {
+	case <-stop:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// playLink streams link on channel, starting at offset when source can seek.
+// Streaming stops after limit, zero limit streams link until its end
+func (ys *YoutubeStreamService) playLink(data *data.StreamItem, youtubeURL string, offset, limit time.Duration) error {
+	if err := ys.history.Record(data.ID, youtubeURL); err != nil {
+		ys.logger.Errorf("Got error %s while saving play history for channel %s", err, data.Name)
+	}
+	ys.state.Play(data.ID, youtubeURL, offset)
+	np := ys.nowPlaying(data, youtubeURL, offset, limit)
+	ys.playing.Publish(np)
+	ys.emit(EventItemStarted, np)
+	opts := stream.Options{Offset: offset, Limit: limit}
+	if ys.s.Config().EmbedMetadata {
+		opts.Metadata = np.metadata()
+	}
+	absFileName := stream.GetFileNameByURL(youtubeURL, ys.s.Config().RootPath)
+	dstURL := fmt.Sprintf("%s/%s", ys.s.Config().RTMPRootServerURL, data.Slug)
+	var err error
+	if ys.isLive(youtubeURL) {
+		err = ys.relayLive(data, youtubeURL, dstURL, limit)
+	} else if _, statErr := os.Stat(absFileName); statErr == nil {
+		ys.logger.Infof(
+			"Streaming channel %s video %s from file",
+			data.Name, youtubeURL,
+		)
+		err = stream.FromLocalFileAt(
+			ys.s.Config().FFMpegPath,
+			absFileName, dstURL, opts,
+		)
+		if err != nil {
+			ys.logger.Errorf("Got error %s while streaming video %s for channel %s", err, youtubeURL, data.Name)
+		}
+	} else {
+		err = ys.streamFromSource(data.Name, youtubeURL, dstURL, opts)
+	}
+	event := ItemEvent{ChannelID: data.ID, Channel: data.Name, URL: youtubeURL}
+	if err != nil {
+		event.Error = err.Error()
+		ys.emit(EventItemFailed, event)
+		return err
+	}
+	ys.emit(EventItemFinished, event)
+	return nil
+}
+
+func (ys *YoutubeStreamService) streamFromSource(channel, link, dstURL string, opts stream.Options) error {
+	src, err := stream.SourceFor(link)
+	if err != nil {
+		ys.logger.Errorf("Got error %s while streaming video %s for channel %s", err, link, channel)
+		return err
+	}
+	ys.logger.Infof(
+		"Streaming channel %s video %s from %s",
+		channel, link, src.Name(),
+	)
+	if seeker, ok := src.(stream.Seeker); ok && (opts.Offset > 0 || opts.Limit > 0 || len(opts.Metadata) > 0) {
+		err = seeker.StreamAt(ys.s.Config().FFMpegPath, link, dstURL, opts)
+	} else {
+		err = src.Stream(ys.s.Config().FFMpegPath, link, dstURL)
+	}
+	if err != nil {
+		ys.logger.Errorf("Got error %s while streaming video %s for channel %s", err, link, channel)
+	}
+	return err
+}
+
 func (ys *YoutubeStreamService) downloadStream(data data.StreamItem) {
 	for e := data.Links.Front(); e != nil; e = e.Next() {
-
 		youtubeURL := fmt.Sprintf("%v", e.Value)
-		absFileName := stream.GetFileNameByURL(youtubeURL, ys.s.Config().RootPath)
-		if !stream.FileExist(absFileName) {
+		ys.downloadVideo(downloadJob{url: youtubeURL, attempt: 1})
+	}
+	ys.s.waitGroup.Done()
+}
 
-			ys.logger.Infof("Downloading video from %s", youtubeURL)
-			ys.logger.Infof("Saving from %s to %s ", youtubeURL, absFileName)
-			err := stream.Download(youtubeURL, absFileName, ys.s.Config().DownloadLimit)
-			if err != nil {
-				ys.logger.Errorf("Got error while downloading video %s  ", err)
-			}
-			ys.logger.Infof("File %s saved for video %s", absFileName, youtubeURL)
+func (ys *YoutubeStreamService) downloadVideo(job downloadJob) {
+	absFileName := stream.GetFileNameByURL(job.url, ys.s.Config().RootPath)
+	if stream.FileExist(absFileName) || ys.isLive(job.url) {
+		return
+	}
+	src, err := stream.SourceFor(job.url)
+	if err != nil {
+		ys.logger.Errorf("Got error while downloading video %s  ", err)
+		ys.emit(EventDownloadFailed, ItemEvent{URL: job.url, Attempt: job.attempt, Error: err.Error()})
+		return
+	}
+	ys.logger.Infof("Downloading video from %s", job.url)
+	ys.logger.Infof("Saving from %s to %s ", job.url, absFileName)
+	verifier := &stream.Verifier{
+		FFMpegPath:  ys.s.Config().FFMpegPath,
+		FFProbePath: ys.s.Config().FFProbePath,
+	}
+	err = src.Download(job.url, absFileName, ys.s.Config().DownloadLimit, verifier)
+	if err == stream.ErrNotDownloadable {
+		ys.logger.Debugf("Video %s can't be cached, it will be streamed from %s", job.url, src.Name())
+		return
+	}
+	if ierr, ok := err.(*stream.IntegrityError); ok {
+		ys.quarantineVideo(job, ierr)
+		return
+	}
+	if err != nil {
+		ys.logger.Errorf("Got error while downloading video %s  ", err)
+		ys.emit(EventDownloadFailed, ItemEvent{URL: job.url, Attempt: job.attempt, Error: err.Error()})
+		return
+	}
+	ys.logger.Infof("File %s saved for video %s", absFileName, job.url)
+	ys.emit(EventDownloadCompleted, ItemEvent{URL: job.url, File: absFileName, Attempt: job.attempt})
+}
+
+func (ys *YoutubeStreamService) quarantineVideo(job downloadJob, ierr *stream.IntegrityError) {
+	ys.logger.Errorf("Video %s is corrupt: %s", job.url, ierr.Reason)
+	quarantined, err := stream.QuarantineFile(ierr.File, ys.s.Config().QuarantinePath)
+	if err != nil {
+		ys.logger.Errorf("Got error %s while moving %s to quarantine", err, ierr.File)
+		os.Remove(ierr.File)
+	}
+	ys.quarantine.Add(QuarantineEntry{
+		URL:     job.url,
+		File:    quarantined,
+		Reason:  ierr.Reason,
+		Attempt: job.attempt,
+		Time:    time.Now(),
+	})
+	ys.state.SetQuarantine(ys.quarantine.Entries())
+	ys.emit(EventItemQuarantined, ItemEvent{URL: job.url, File: quarantined, Attempt: job.attempt, Error: ierr.Reason})
+	if job.attempt >= maxDownloadAttempts {
+		ys.logger.Errorf("Giving up downloading %s after %d attempts", job.url, job.attempt)
+		return
+	}
+	next := downloadJob{url: job.url, attempt: job.attempt + 1}
+	time.AfterFunc(time.Duration(job.attempt*job.attempt)*time.Minute, func() {
+		if ys.IsNeedStop() {
+			return
 		}
+		select {
+		case ys.redownloads <- next:
+		default:
+			ys.logger.Errorf("Re-download queue is full, skipping %s", next.url)
+		}
+	})
+}
+
+func (ys *YoutubeStreamService) runRedownloads() {
+	defer ys.s.waitGroup.Done()
+	for job := range ys.redownloads {
+		if ys.IsNeedStop() {
+			return
+		}
+		ys.logger.Infof("Re-downloading %s, attempt %d", job.url, job.attempt)
+		ys.downloadVideo(job)
 	}
-	ys.s.waitGroup.Done()
+}
+
+// QuotaUsage returns YouTube Data API quota spent today
+func (ys *YoutubeStreamService) QuotaUsage() bot.QuotaUsage {
+	return ys.yc.QuotaUsage()
+}
+
+// AddAPIKey adds YouTube Data API key into pool
+func (ys *YoutubeStreamService) AddAPIKey(key string) bool {
+	return ys.yc.AddKey(key)
+}
+
+// RemoveAPIKey removes YouTube Data API key from pool
+func (ys *YoutubeStreamService) RemoveAPIKey(key string) bool {
+	return ys.yc.RemoveKey(key)
+}
+
+// APIKeys returns usage and health of YouTube Data API keys
+func (ys *YoutubeStreamService) APIKeys() []bot.KeyStatus {
+	return ys.yc.KeyStatus()
+}
+
+// Collisions returns collision window and recent conflicts between channels
+func (ys *YoutubeStreamService) Collisions() (time.Duration, []Conflict) {
+	return ys.collisions.Window(), ys.collisions.Conflicts()
+}
+
+// Quarantine returns reports about downloaded files that failed verification
+func (ys *YoutubeStreamService) Quarantine() []QuarantineEntry {
+	return ys.quarantine.Entries()
+}
+
+// PushLink adds link to the beginning of stream and plays it right after
+// current video. Links that stream already has or has aired are skipped,
+// it returns false then
+func (ys *YoutubeStreamService) PushLink(streamID int, link string) bool {
+	if ys.history.Played(streamID, link) {
+		return false
+	}
+	ys.ss.Lock()
+	links := ys.ss.Items[streamID].Links
+	if links == nil {
+		ys.ss.Unlock()
+		ys.logger.Errorf("stream %d does not exist in storage", streamID)
+		return false
+	}
+	for e := links.Front(); e != nil; e = e.Next() {
+		if fmt.Sprintf("%v", e.Value) == link {
+			ys.ss.Unlock()
+			return false
+		}
+	}
+	links.PushFront(link)
+	ys.ss.Unlock()
+
+	ys.breakingMu.Lock()
+	ys.breaking[streamID] = append(ys.breaking[streamID], link)
+	ys.breakingMu.Unlock()
+	return true
+}
+
+func (ys *YoutubeStreamService) nextBreaking(streamID int) (string, bool) {
+	ys.breakingMu.Lock()
+	defer ys.breakingMu.Unlock()
+	queue := ys.breaking[streamID]
+	if len(queue) == 0 {
+		return "", false
+	}
+	ys.breaking[streamID] = queue[1:]
+	return queue[0], true
+}
+
+// followChannels subscribes news autostream to push notifications
+// about uploads of its channels
+func (ys *YoutubeStreamService) followChannels(as data.Stream) {
+	var channelIDs []string
+	for _, channel := range strings.Split(as.Channels+","+as.Feeds, ",") {
+		channel = strings.TrimSpace(channel)
+		if channel == "" {
+			continue
+		}
+		channelID, err := ys.yc.ChannelID(channel)
+		if err != nil {
+			ys.logger.Errorf("Error while resolving channel %s, %v", channel, err)
+			continue
+		}
+		channelIDs = append(channelIDs, channelID)
+	}
+	ys.s.WebSubService().SetStreamChannels(as.ID, channelIDs)
 }
 
 // AddStream adds stream and runs it gracefully
@@ -173,10 +529,10 @@ func (ys *YoutubeStreamService) AddStream(stream data.StreamItem, download bool)
 	ys.ss.Items[stream.ID] = stream
 	ys.ss.Unlock()
 	ys.logger.Infof("Starting streaming of %s", stream.Name)
-	ys.s.waitGroup.Add(1)
 
 	if !ys.s.Config().DisableStreaming {
-		go ys.runStream(stream)
+		ys.s.waitGroup.Add(1)
+		go ys.runStream(stream, ys.streamStop(stream.ID))
 	}
 	ys.s.waitGroup.Add(1)
 	if download {
@@ -188,8 +544,11 @@ func (ys *YoutubeStreamService) AddStream(stream data.StreamItem, download bool)
 func (ys *YoutubeStreamService) UpdateStream(stream data.Stream, download bool) {
 	ys.ss.Lock()
 	item, ok := ys.ss.Items[stream.ID]
-	if !ok {
+	// stream may be removed while it's refreshed or rescanned
+	if _, defined := ys.definition(stream.ID); !ok || !defined {
+		ys.ss.Unlock()
 		ys.logger.Errorf("%s does not exist in storage", stream.Name)
+		return
 	}
 	item.Lock()
 	item.Links.Init()
@@ -201,54 +560,95 @@ func (ys *YoutubeStreamService) UpdateStream(stream data.Stream, download bool)
 	item.Name = stream.Name
 	ys.ss.Unlock()
 	if download {
+		ys.s.waitGroup.Add(1)
 		go ys.downloadStream(stream.ToStreamItem())
 	}
 }
 
 func (ys *YoutubeStreamService) runJobsForAutoStream(autoStream data.Stream, update bool) {
-
+	if autoStream.IsNews && ys.s.Config().WebSubCallbackURL != "" {
+		ys.followChannels(autoStream)
+	}
 	streamData := ys.createStream(autoStream)
 	streamData.IsAuto = true
-	if len(streamData.Links) == 0 {
-		return
-	}
-	if !update {
+	switch {
+	case !update:
+		// stream with nothing found yet is added too, refresh fills it
 		ys.AddStream(streamData.ToStreamItem(), false)
-	} else {
+	case len(streamData.Links) > 0:
 		ys.UpdateStream(streamData, false)
-		ys.s.waitGroup.Add(1)
-		go ys.runUpdateStream(autoStream)
+		ys.queueLive(autoStream.ID, streamData.Links)
+		ys.emit(EventAutostreamRefreshed, ChannelEvent{ChannelID: autoStream.ID, Channel: autoStream.Name, Links: len(streamData.Links)})
 	}
+	ys.s.waitGroup.Add(1)
+	go ys.runUpdateStream(autoStream, ys.refreshStop(autoStream.ID))
 }
 
 func (ys *YoutubeStreamService) createStream(as data.Stream) data.Stream {
-	var links []data.StreamLink
+	var candidates []candidate
 	var streamData data.Stream
 	streamData.ID = as.ID
 	streamData.Name = as.Name
 	streamData.Slug = as.Slug
 	if as.Keywords != "" {
 		for _, keyword := range strings.Split(as.Keywords, ",") {
-			youtubeLinks, err := ys.getYoutubeContent(keyword, false, as.IsNews)
+			found, err := ys.getYoutubeContent(keyword, false, as)
 			if err != nil {
 				ys.logger.Errorf("Error while requesting data, %v", err)
 				continue
 			}
-			links = append(links, youtubeLinks...)
+			candidates = append(candidates, found...)
 		}
 	}
 	if as.Channels != "" {
 
 		for _, channel := range strings.Split(as.Channels, ",") {
-			youtubeLinks, err := ys.getYoutubeContent(channel, true, as.IsNews)
+			var found []candidate
+			var err error
+			if as.UseFeeds {
+				found, err = ys.getFeedContent(channel, as)
+			} else {
+				found, err = ys.getYoutubeContent(channel, true, as)
+			}
 			if err != nil {
 				ys.logger.Errorf("Error while requesting data, %v", err)
 				continue
 			}
-			links = append(links, youtubeLinks...)
+			candidates = append(candidates, found...)
+		}
+	}
+	if as.Feeds != "" {
+		for _, channel := range strings.Split(as.Feeds, ",") {
+			found, err := ys.getFeedContent(channel, as)
+			if err != nil {
+				ys.logger.Errorf("Error while requesting feed of %s, %v", channel, err)
+				continue
+			}
+			candidates = append(candidates, found...)
+		}
+	}
+	links := ys.orderCandidates(as, candidates)
+	if as.Playlists != "" {
+		var playlistLinks []data.StreamLink
+		for _, playlist := range strings.Split(as.Playlists, ",") {
+			youtubeLinks, err := ys.getPlaylistContent(playlist)
+			if err != nil {
+				ys.logger.Errorf("Error while requesting playlist %s, %v", playlist, err)
+				continue
+			}
+			playlistLinks = append(playlistLinks, youtubeLinks...)
+		}
+		if as.Shuffle {
+			links = ys.shuffleLinks(append(links, playlistLinks...))
+		} else {
+			links = append(playlistLinks, links...)
 		}
 	}
-	streamData.Links = ys.shuffleLinks(links)
+	streamData.Links = ys.collisions.Reorder(as.ID, ys.avoidRepeats(as, links))
+	if as.IncludeLive {
+		live := ys.createLiveLinks(as)
+		streamData.Links = append(live, withoutLinks(streamData.Links, live)...)
+	}
 	ys.logger.Info("Exiting")
 	return streamData
 }
@@ -261,44 +661,88 @@ func (ys *YoutubeStreamService) shuffleLinks(links []data.StreamLink) []data.Str
 	return links
 }
 
-func (ys *YoutubeStreamService) getYoutubeContent(keyword string, isChannel, isNews bool) ([]data.StreamLink, error) {
-	var links []data.StreamLink
+func (ys *YoutubeStreamService) getYoutubeContent(keyword string, isChannel bool, as data.Stream) ([]candidate, error) {
 	var results []*youtube.SearchResult
 	var err error
+	filter := searchFilter(as)
 	if isChannel {
-		if isNews {
-			results, err = ys.yc.SearchOnChannelByTime(keyword)
+		if as.IsNews {
+			results, err = ys.yc.SearchOnChannelByTime(keyword, as.MaxResults, filter)
 		} else {
-			results, err = ys.yc.SearchOnChannel(keyword)
+			results, err = ys.yc.SearchOnChannel(keyword, as.MaxResults, filter)
 		}
 	} else {
-		results, err = ys.yc.Search(keyword)
+		results, err = ys.yc.Search(keyword, as.MaxResults, filter)
 	}
 	if err != nil {
 		return nil, err
 	}
-	for _, item := range results {
-		switch item.Id.Kind {
-		case "youtube#video":
-			links = append(links, data.StreamLink{
-				URL: fmt.Sprintf("https://youtube.com/watch?v=%s", item.Id.VideoId),
-			})
-		default:
+	results = ys.filterByDuration(as, filterResults(as, results))
+	return toCandidates(as, keyword, results), nil
+}
+
+func (ys *YoutubeStreamService) getPlaylistContent(playlist string) ([]data.StreamLink, error) {
+	playlistID := strings.TrimSpace(playlist)
+	if u, err := url.Parse(playlistID); err == nil && u.Query().Get("list") != "" {
+		playlistID = u.Query().Get("list")
+	}
+	items, err := ys.yc.PlaylistItems(playlistID)
+	if err != nil {
+		return nil, err
+	}
+	var links []data.StreamLink
+	for _, item := range items {
+		if item.ContentDetails == nil || item.ContentDetails.VideoId == "" {
+			continue
+		}
+		if item.Status != nil && item.Status.PrivacyStatus == "private" {
 			continue
 		}
+		links = append(links, data.StreamLink{
+			URL: videoURL(item.ContentDetails.VideoId),
+		})
 	}
 	return links, nil
 }
 
-func (ys *YoutubeStreamService) runUpdateStream(as data.Stream) {
+// emit sends event to webhooks
+func (ys *YoutubeStreamService) emit(event string, data interface{}) {
+	ys.s.WebhookService().Emit(event, data)
+}
+
+func videoURL(videoID string) string {
+	return fmt.Sprintf("https://youtube.com/watch?v=%s", videoID)
+}
 
-	for range time.Tick(time.Duration(as.UpdateFrequency) * time.Second) {
+// runUpdateStream refreshes autostream until it's removed or its
+// definition is changed, updated definition runs its own refresh
+func (ys *YoutubeStreamService) runUpdateStream(as data.Stream, stop <-chan struct{}) {
+	defer ys.s.waitGroup.Done()
+	if as.UpdateFrequency <= 0 {
+		return
+	}
+	ticker := time.NewTicker(time.Duration(as.UpdateFrequency) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+		if current, ok := ys.definition(as.ID); !ok || !reflect.DeepEqual(current, as) || ys.IsNeedStop() {
+			return
+		}
 		ys.logger.Infof("Updating stream %s", as.Name)
 		streamData := ys.createStream(as)
+		if len(streamData.Links) == 0 {
+			ys.logger.Infof("Nothing found for stream %s, keeping current queue", as.Name)
+			continue
+		}
 
 		ys.UpdateStream(streamData, false)
+		ys.queueLive(as.ID, streamData.Links)
+		ys.emit(EventAutostreamRefreshed, ChannelEvent{ChannelID: as.ID, Channel: as.Name, Links: len(streamData.Links)})
 	}
-	ys.s.waitGroup.Done()
 }
 
 func (ys *YoutubeStreamService) AddAutoStream(as data.Stream) {
@@ -0,0 +1,135 @@
+package stream
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// mediaExtensions used when directory is scanned without patterns
+var mediaExtensions = map[string]bool{
+	".mp4":  true,
+	".m4v":  true,
+	".mkv":  true,
+	".mov":  true,
+	".flv":  true,
+	".ts":   true,
+	".webm": true,
+	".avi":  true,
+}
+
+// FileSource handles file:// links to local media library
+type FileSource struct{}
+
+// Name returns name of provider
+func (s *FileSource) Name() string {
+	return "file"
+}
+
+// Match returns true for file links
+func (s *FileSource) Match(u *url.URL) bool {
+	return u.Scheme == "file"
+}
+
+// Resolve returns path to local file
+func (s *FileSource) Resolve(link string) (*Media, error) {
+	return s.Probe(link)
+}
+
+// Probe checks that local file exists
+func (s *FileSource) Probe(link string) (*Media, error) {
+	fileName, err := FileNameByLink(link)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(fileName); err != nil {
+		return nil, err
+	}
+	return &Media{Title: filepath.Base(fileName), URL: fileName}, nil
+}
+
+// Download does nothing, local files are streamed directly
+func (s *FileSource) Download(link, fileName string, downloadLimit int, v *Verifier) error {
+	return ErrNotDownloadable
+}
+
+// Stream re-streams local file to dst
+func (s *FileSource) Stream(ffmpeg, link, dst string) error {
+	fileName, err := FileNameByLink(link)
+	if err != nil {
+		return err
+	}
+	return FromLocalFile(ffmpeg, fileName, dst)
+}
+
+// FileLink returns file:// link for local file
+func FileLink(fileName string) string {
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(fileName)}
+	return u.String()
+}
+
+// FileNameByLink returns local file name from file:// link
+func FileNameByLink(link string) (string, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("%s is not a file link", link)
+	}
+	return filepath.FromSlash(u.Path), nil
+}
+
+// ScanDirectory returns sorted list of media files in dir. Files are matched
+// by glob patterns against their base name or by known media extensions
+func ScanDirectory(dir string, recursive bool, patterns []string) ([]string, error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != root && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(path, ".download") || strings.HasPrefix(info.Name(), ".") {
+			return nil
+		}
+		ok, err := isMediaFile(info.Name(), patterns)
+		if err != nil {
+			return err
+		}
+		if ok {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func isMediaFile(name string, patterns []string) (bool, error) {
+	if len(patterns) == 0 {
+		return mediaExtensions[strings.ToLower(filepath.Ext(name))], nil
+	}
+	for _, pattern := range patterns {
+		ok, err := filepath.Match(pattern, name)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
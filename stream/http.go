@@ -0,0 +1,130 @@
+package stream
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/mxk/go-flowrate/flowrate"
+)
+
+// HTTPSource handles direct media links and HLS/DASH manifests
+type HTTPSource struct{}
+
+// Name returns name of provider
+func (s *HTTPSource) Name() string {
+	return "http"
+}
+
+// Match returns true for any http or https link
+func (s *HTTPSource) Match(u *url.URL) bool {
+	return u.Scheme == "http" || u.Scheme == "https"
+}
+
+// Resolve returns link itself, because it's already streamable
+func (s *HTTPSource) Resolve(link string) (*Media, error) {
+	return s.Probe(link)
+}
+
+// Probe performs HEAD request to check that media is reachable
+func (s *HTTPSource) Probe(link string) (*Media, error) {
+	resp, err := http.Head(link)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("Invalid status code: %d", resp.StatusCode)
+	}
+	return &Media{
+		Title:      path.Base(resp.Request.URL.Path),
+		URL:        link,
+		IsManifest: isManifest(link, resp.Header.Get("Content-Type")),
+	}, nil
+}
+
+// Download saves media into fileName. Manifests are not downloadable
+func (s *HTTPSource) Download(link, fileName string, downloadLimit int, v *Verifier) error {
+	if isManifest(link, "") {
+		return ErrNotDownloadable
+	}
+	return downloadURL(link, nil, fileName, downloadLimit, v)
+}
+
+// Stream re-streams media to dst
+func (s *HTTPSource) Stream(ffmpeg, link, dst string) error {
+	return FromURL(ffmpeg, link, dst)
+}
+
+// FromURL streams from direct media link or manifest
+func FromURL(ffmpeg, src, dst string) error {
+	return FromURLAt(ffmpeg, src, dst, Options{})
+}
+
+func isManifest(link, contentType string) bool {
+	switch strings.ToLower(path.Ext(strings.SplitN(link, "?", 2)[0])) {
+	case ".m3u8", ".mpd":
+		return true
+	}
+	switch strings.ToLower(strings.SplitN(contentType, ";", 2)[0]) {
+	case "application/vnd.apple.mpegurl", "application/x-mpegurl", "application/dash+xml":
+		return true
+	}
+	return false
+}
+
+// downloadURL downloads src with HTTP headers into fileName. The file is promoted
+// to its final name only if its size matches Content-Length and it passes verification
+func downloadURL(src string, headers map[string]string, fileName string, downloadLimit int, v *Verifier) error {
+	dst := fmt.Sprintf("%s.download", fileName)
+	file, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	req, err := http.NewRequest(http.MethodGet, src, nil)
+	if err != nil {
+		os.Remove(dst)
+		return err
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		os.Remove(dst)
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		os.Remove(dst)
+		return fmt.Errorf("Invalid status code: %d", resp.StatusCode)
+	}
+	wrappedIn := flowrate.NewReader(resp.Body, int64(downloadLimit)*1024)
+	written, err := io.Copy(file, wrappedIn)
+	if err != nil {
+		os.Remove(dst)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	if resp.ContentLength >= 0 && written != resp.ContentLength {
+		return &IntegrityError{
+			File:   dst,
+			Reason: fmt.Sprintf("got %d bytes, expected %d", written, resp.ContentLength),
+		}
+	}
+	if err := v.Verify(dst); err != nil {
+		if _, ok := err.(*IntegrityError); !ok {
+			os.Remove(dst)
+		}
+		return err
+	}
+	return RenameFile(fileName)
+}
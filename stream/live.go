@@ -0,0 +1,71 @@
+package stream
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// LiveStreamer is implemented by sources that can relay live broadcasts
+type LiveStreamer interface {
+	// StreamLive relays live broadcast to dst until it ends, zero limit
+	// relays broadcast until its end
+	StreamLive(ffmpeg, link, dst string, limit time.Duration) error
+}
+
+var hlsManifestRe = regexp.MustCompile(`"hlsManifestUrl":"([^"]+)"`)
+
+// StreamLive relays live broadcast through its HLS manifest until it ends
+// or limit passes
+func (s *YoutubeSource) StreamLive(ffmpeg, link, dst string, limit time.Duration) error {
+	manifest, headers, err := s.liveManifestURL(link)
+	if err != nil {
+		return fmt.Errorf("Got error %s while getting live manifest for video %s ", err, link)
+	}
+	return FromLiveURL(ffmpeg, manifest, dst, headers, limit)
+}
+
+// liveManifestURL returns HLS manifest of live broadcast. Resolver is tried
+// first, manifest from watch page is used as fallback. Headers required to
+// fetch manifest are returned with it
+func (s *YoutubeSource) liveManifestURL(link string) (string, map[string]string, error) {
+	if media, err := s.Resolve(link); err == nil && media.IsManifest {
+		return media.URL, media.Headers, nil
+	}
+	resp, err := http.Get(link)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", nil, fmt.Errorf("Invalid status code: %d", resp.StatusCode)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", nil, err
+	}
+	match := hlsManifestRe.FindSubmatch(body)
+	if match == nil {
+		return "", nil, fmt.Errorf("broadcast is not live")
+	}
+	manifest := strings.NewReplacer(`\/`, "/", `\u0026`, "&").Replace(string(match[1]))
+	return manifest, nil, nil
+}
+
+// FromLiveURL relays live HLS manifest with HTTP headers, relay stops after
+// limit unless it's zero. Unlike FromURL input is not throttled with -re,
+// because live manifest is already paced
+func FromLiveURL(ffmpeg, manifest, dst string, headers map[string]string, limit time.Duration) error {
+	ffmpegArgs := append(headerArgs(headers), "-i", manifest)
+	if limit > 0 {
+		ffmpegArgs = append(ffmpegArgs, "-t", fmt.Sprintf("%.3f", limit.Seconds()))
+	}
+	ffmpegArgs = append(ffmpegArgs, "-c", "copy", "-f", "flv", dst)
+	cmd := exec.Command(ffmpeg, ffmpegArgs...)
+	_, err := cmd.CombinedOutput()
+	return err
+}
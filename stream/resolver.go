@@ -0,0 +1,123 @@
+package stream
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/otium/ytdl"
+)
+
+// ResolverBuiltin, ResolverExtractor and ResolverFallback are names of
+// resolver modes that can be selected for deployment
+const (
+	ResolverBuiltin   = "builtin"
+	ResolverExtractor = "extractor"
+	ResolverFallback  = "fallback"
+)
+
+// Resolver resolves video link into streamable media
+type Resolver interface {
+	Resolve(link string) (*Media, error)
+}
+
+// NewResolver creates resolver by mode. extractorPath is used for
+// extractor and fallback modes
+func NewResolver(mode, extractorPath string) (Resolver, error) {
+	switch mode {
+	case ResolverBuiltin, "":
+		return &YtdlResolver{}, nil
+	case ResolverExtractor:
+		return &ExtractorResolver{Path: extractorPath}, nil
+	case ResolverFallback:
+		return FallbackResolver{&YtdlResolver{}, &ExtractorResolver{Path: extractorPath}}, nil
+	}
+	return nil, fmt.Errorf("unknown resolver %q", mode)
+}
+
+// YtdlResolver resolves links with built-in ytdl decipher
+type YtdlResolver struct{}
+
+// Resolve returns media with deciphered streamable url
+func (r *YtdlResolver) Resolve(link string) (*Media, error) {
+	info, err := ytdl.GetVideoInfo(link)
+	if err != nil {
+		return nil, err
+	}
+	u, err := info.GetDownloadURL(getBestFormat(info.Formats))
+	if err != nil {
+		return nil, err
+	}
+	return &Media{Title: info.Title, URL: u.String(), Duration: info.Duration}, nil
+}
+
+// ExtractorResolver resolves links with external yt-dlp compatible binary
+type ExtractorResolver struct {
+	Path   string
+	Format string
+}
+
+// defaultExtractorFormat mirrors format selected by getBestFormat
+const defaultExtractorFormat = "best[ext=mp4][height<=480]/best[ext=mp4]/best"
+
+type extractorInfo struct {
+	Title    string  `json:"title"`
+	Duration float64 `json:"duration"`
+	URL      string  `json:"url"`
+	Protocol string  `json:"protocol"`
+	// HTTPHeaders are headers like User-Agent or Referer that url is bound to
+	HTTPHeaders map[string]string `json:"http_headers"`
+}
+
+// Resolve runs extractor and parses its JSON output
+func (r *ExtractorResolver) Resolve(link string) (*Media, error) {
+	format := r.Format
+	if format == "" {
+		format = defaultExtractorFormat
+	}
+	extractorArgs := []string{
+		"--dump-single-json", "--no-playlist", "--no-warnings",
+		"-f", format,
+		link,
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command(r.Path, extractorArgs...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("extractor failed: %v %s", err, strings.TrimSpace(stderr.String()))
+	}
+	var info extractorInfo
+	if err := json.Unmarshal(out, &info); err != nil {
+		return nil, fmt.Errorf("can't parse extractor output: %v", err)
+	}
+	if info.URL == "" {
+		return nil, fmt.Errorf("extractor returned no url for %s", link)
+	}
+	return &Media{
+		Title:      info.Title,
+		URL:        info.URL,
+		Duration:   time.Duration(info.Duration * float64(time.Second)),
+		IsManifest: strings.HasPrefix(info.Protocol, "m3u8"),
+		Headers:    info.HTTPHeaders,
+	}, nil
+}
+
+// FallbackResolver tries resolvers in order until one succeeds
+type FallbackResolver []Resolver
+
+// Resolve returns media from first resolver that succeeded
+func (r FallbackResolver) Resolve(link string) (*Media, error) {
+	var errs []string
+	for _, resolver := range r {
+		media, err := resolver.Resolve(link)
+		if err == nil {
+			return media, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return nil, fmt.Errorf("all resolvers failed for %s: %s", link, strings.Join(errs, "; "))
+}
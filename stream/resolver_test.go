@@ -0,0 +1,124 @@
+package stream
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubExtractor writes executable that behaves like yt-dlp for given links
+func stubExtractor(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "extractor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	script := `#!/bin/sh
+for arg in "$@"; do link="$arg"; done
+case "$link" in
+*video)
+	echo '{"title": "Video", "duration": 61.5, "url": "https://cdn.example/video.mp4", "protocol": "https",
+		"http_headers": {"User-Agent": "stub-agent", "Referer": "https://www.youtube.com/"}}'
+	;;
+*live)
+	echo '{"title": "Live", "url": "https://cdn.example/live.m3u8", "protocol": "m3u8_native"}'
+	;;
+*nourl)
+	echo '{"title": "No url"}'
+	;;
+*garbage)
+	echo 'not json'
+	;;
+*)
+	echo "ERROR: video unavailable" >&2
+	exit 1
+	;;
+esac
+`
+	path := filepath.Join(dir, "yt-dlp")
+	if err := ioutil.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExtractorResolver(t *testing.T) {
+	r := &ExtractorResolver{Path: stubExtractor(t)}
+
+	media, err := r.Resolve("https://youtube.com/video")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if media.Title != "Video" || media.URL != "https://cdn.example/video.mp4" || media.IsManifest {
+		t.Fatalf("unexpected media %+v", media)
+	}
+	if media.Duration != 61500*time.Millisecond {
+		t.Fatalf("unexpected duration %s", media.Duration)
+	}
+	if media.Headers["User-Agent"] != "stub-agent" || media.Headers["Referer"] != "https://www.youtube.com/" {
+		t.Fatalf("headers of extractor are lost: %v", media.Headers)
+	}
+
+	media, err = r.Resolve("https://youtube.com/live")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !media.IsManifest {
+		t.Fatal("m3u8 protocol must be resolved as manifest")
+	}
+
+	if _, err := r.Resolve("https://youtube.com/missing"); err == nil || !strings.Contains(err.Error(), "video unavailable") {
+		t.Fatalf("expected extractor error with stderr, got %v", err)
+	}
+	if _, err := r.Resolve("https://youtube.com/garbage"); err == nil {
+		t.Fatal("expected error for invalid extractor output")
+	}
+	if _, err := r.Resolve("https://youtube.com/nourl"); err == nil {
+		t.Fatal("expected error for output without url")
+	}
+}
+
+func TestFallbackResolver(t *testing.T) {
+	failing := &ExtractorResolver{Path: filepath.Join(os.TempDir(), "missing-extractor")}
+	r := FallbackResolver{failing, &ExtractorResolver{Path: stubExtractor(t)}}
+	media, err := r.Resolve("https://youtube.com/video")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if media.URL != "https://cdn.example/video.mp4" {
+		t.Fatalf("unexpected media %+v", media)
+	}
+	if _, err := r.Resolve("https://youtube.com/missing"); err == nil {
+		t.Fatal("expected error when every resolver fails")
+	}
+}
+
+func TestHeadersPassedToFFmpeg(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ffmpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	args := filepath.Join(dir, "args")
+	ffmpeg := filepath.Join(dir, "ffmpeg")
+	script := "#!/bin/sh\nfor arg in \"$@\"; do printf '%s\\n' \"$arg\" >> " + args + "; done\n"
+	if err := ioutil.WriteFile(ffmpeg, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	source := &YoutubeSource{Resolver: &ExtractorResolver{Path: stubExtractor(t)}}
+	if err := source.Stream(ffmpeg, "https://youtube.com/video", "rtmp://localhost/hls/test"); err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadFile(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(body)
+	headers := "-headers\nReferer: https://www.youtube.com/\r\nUser-Agent: stub-agent\r\n\n-re\n-i\nhttps://cdn.example/video.mp4\n"
+	if !strings.HasPrefix(got, headers) {
+		t.Fatalf("headers must precede input, got ffmpeg args %q", got)
+	}
+}
@@ -0,0 +1,101 @@
+package stream
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Options controls part of media that is streamed and metadata of output
+type Options struct {
+	// Offset is position in media where streaming starts
+	Offset time.Duration
+	// Limit stops streaming after given time, zero streams media until its end
+	Limit time.Duration
+	// Metadata is written to output, FLV output carries it in onMetaData
+	Metadata map[string]string
+	// Headers are sent with HTTP requests for input
+	Headers map[string]string
+}
+
+// Seeker is implemented by sources that can stream part of media
+type Seeker interface {
+	// StreamAt re-streams media to dst with options
+	StreamAt(ffmpeg, link, dst string, opts Options) error
+}
+
+// StreamAt re-streams media to dst with options
+func (s *HTTPSource) StreamAt(ffmpeg, link, dst string, opts Options) error {
+	return FromURLAt(ffmpeg, link, dst, opts)
+}
+
+// StreamAt re-streams local file to dst with options
+func (s *FileSource) StreamAt(ffmpeg, link, dst string, opts Options) error {
+	fileName, err := FileNameByLink(link)
+	if err != nil {
+		return err
+	}
+	return FromLocalFileAt(ffmpeg, fileName, dst, opts)
+}
+
+// StreamAt re-streams video to dst with options
+func (s *YoutubeSource) StreamAt(ffmpeg, link, dst string, opts Options) error {
+	media, err := s.Resolve(link)
+	if err != nil {
+		return fmt.Errorf("Got error %s while getting streamable  youtube url for video %s ", err, link)
+	}
+	opts.Headers = media.Headers
+	return FromURLAt(ffmpeg, media.URL, dst, opts)
+}
+
+// FromLocalFileAt streams video from local file with options
+func FromLocalFileAt(ffmpeg, fileName, dst string, opts Options) error {
+	return fromInputAt(ffmpeg, fileName, dst, opts)
+}
+
+// FromURLAt streams from direct media link or manifest with options
+func FromURLAt(ffmpeg, src, dst string, opts Options) error {
+	return fromInputAt(ffmpeg, src, dst, opts)
+}
+
+// fromInputAt seeks input before decoding, so streaming starts right away
+func fromInputAt(ffmpeg, input, dst string, opts Options) error {
+	ffmpegArgs := headerArgs(opts.Headers)
+	if opts.Offset > 0 {
+		ffmpegArgs = append(ffmpegArgs, "-ss", fmt.Sprintf("%.3f", opts.Offset.Seconds()))
+	}
+	ffmpegArgs = append(ffmpegArgs, "-re", "-i", input)
+	if opts.Limit > 0 {
+		ffmpegArgs = append(ffmpegArgs, "-t", fmt.Sprintf("%.3f", opts.Limit.Seconds()))
+	}
+	for _, key := range sortedKeys(opts.Metadata) {
+		ffmpegArgs = append(ffmpegArgs, "-metadata", fmt.Sprintf("%s=%s", key, opts.Metadata[key]))
+	}
+	ffmpegArgs = append(ffmpegArgs, "-c", "copy", "-f", "flv", dst)
+	cmd := exec.Command(ffmpeg, ffmpegArgs...)
+	_, err := cmd.CombinedOutput()
+	return err
+}
+
+// headerArgs returns ffmpeg input option with HTTP headers
+func headerArgs(headers map[string]string) []string {
+	if len(headers) == 0 {
+		return nil
+	}
+	var lines []string
+	for _, key := range sortedKeys(headers) {
+		lines = append(lines, key+": "+headers[key]+"\r\n")
+	}
+	return []string{"-headers", strings.Join(lines, "")}
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,74 @@
+package stream
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ErrNotDownloadable is returned by sources which media can't be cached locally,
+// e.g. HLS or DASH manifests
+var ErrNotDownloadable = errors.New("media can't be downloaded")
+
+// Media describes media resolved by Source
+type Media struct {
+	Title      string
+	URL        string
+	Duration   time.Duration
+	IsManifest bool
+	// Headers are HTTP headers required to fetch URL
+	Headers map[string]string
+}
+
+// Source is a provider of media, chosen by link scheme or host
+type Source interface {
+	// Name returns name of provider
+	Name() string
+	// Match returns true if provider can handle link
+	Match(u *url.URL) bool
+	// Resolve returns media with url ready for re-streaming
+	Resolve(link string) (*Media, error)
+	// Probe returns media metadata without resolving streamable url when possible
+	Probe(link string) (*Media, error)
+	// Download saves media into fileName
+	Download(link, fileName string, downloadLimit int, v *Verifier) error
+	// Stream re-streams media to dst
+	Stream(ffmpeg, link, dst string) error
+}
+
+var (
+	sourcesMu sync.RWMutex
+	sources   []Source
+)
+
+func init() {
+	RegisterSource(&HTTPSource{})
+	RegisterSource(&FileSource{})
+	RegisterSource(&YoutubeSource{})
+}
+
+// RegisterSource adds provider to registry. Providers registered later
+// take precedence over earlier ones
+func RegisterSource(s Source) {
+	sourcesMu.Lock()
+	sources = append(sources, s)
+	sourcesMu.Unlock()
+}
+
+// SourceFor returns provider that can handle link
+func SourceFor(link string) (Source, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil, err
+	}
+	sourcesMu.RLock()
+	defer sourcesMu.RUnlock()
+	for i := len(sources) - 1; i >= 0; i-- {
+		if sources[i].Match(u) {
+			return sources[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no source provider for %s", link)
+}
@@ -31,17 +31,7 @@ func FromYoutubeURL(ffmpeg, youtubeURL, dst, rootPath string) error {
 
 // FromLocalFile streams video from local file downloaded from youtube
 func FromLocalFile(ffmpeg, fileName, dst string) error {
-
-	ffmpegArgs := []string{
-		"-re", "-i", fileName,
-		"-c", "copy", "-f", "flv", dst,
-	}
-	cmd := exec.Command(ffmpeg, ffmpegArgs...)
-	_, err := cmd.CombinedOutput()
-	if err != nil {
-		return err
-	}
-	return nil
+	return FromLocalFileAt(ffmpeg, fileName, dst, Options{})
 }
 
 // GetFileNameByURL returns MD5 hash from youtube url
@@ -0,0 +1,115 @@
+package stream
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IntegrityError is returned when downloaded file did not pass verification.
+// File points to the file that should be quarantined
+type IntegrityError struct {
+	File   string
+	Reason string
+}
+
+func (e *IntegrityError) Error() string {
+	return fmt.Sprintf("integrity check failed for %s: %s", e.File, e.Reason)
+}
+
+// Verifier checks downloaded files with ffprobe and a full ffmpeg decode pass
+type Verifier struct {
+	FFMpegPath  string
+	FFProbePath string
+}
+
+// Verify returns IntegrityError if file can't be probed or decoded. Failure
+// to run ffprobe or ffmpeg is returned as is, file is not blamed for it
+func (v *Verifier) Verify(fileName string) error {
+	if v == nil {
+		return nil
+	}
+	if v.FFProbePath != "" {
+		if err := v.probe(fileName); err != nil {
+			return integrityError(fileName, err)
+		}
+	}
+	if v.FFMpegPath != "" {
+		if err := v.decode(fileName); err != nil {
+			return integrityError(fileName, err)
+		}
+	}
+	return nil
+}
+
+// integrityError returns IntegrityError unless tool failed to start,
+// e.g. it's missing or not executable
+func integrityError(fileName string, err error) error {
+	var exitErr *exec.ExitError
+	var execErr *exec.Error
+	var pathErr *os.PathError
+	if !errors.As(err, &exitErr) && (errors.As(err, &execErr) || errors.As(err, &pathErr)) {
+		return err
+	}
+	return &IntegrityError{File: fileName, Reason: err.Error()}
+}
+
+func (v *Verifier) probe(fileName string) error {
+	_, err := ProbeDuration(v.FFProbePath, fileName)
+	return err
+}
+
+// ProbeDuration returns duration of media reported by ffprobe
+func ProbeDuration(ffprobe, input string) (time.Duration, error) {
+	ffprobeArgs := []string{
+		"-v", "error",
+		"-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		input,
+	}
+	out, err := exec.Command(ffprobe, ffprobeArgs...).Output()
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe failed: %w", err)
+	}
+	duration, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("ffprobe reported invalid duration %q", strings.TrimSpace(string(out)))
+	}
+	return time.Duration(duration * float64(time.Second)), nil
+}
+
+func (v *Verifier) decode(fileName string) error {
+	ffmpegArgs := []string{
+		"-v", "error", "-xerror",
+		"-i", fileName,
+		"-f", "null", "-",
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command(v.FFMpegPath, ffmpegArgs...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("decode failed: %w %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return fmt.Errorf("decode reported errors: %s", msg)
+	}
+	return nil
+}
+
+// QuarantineFile moves file into quarantine directory and returns its new path
+func QuarantineFile(fileName, quarantinePath string) (string, error) {
+	if err := os.MkdirAll(quarantinePath, 0755); err != nil {
+		return "", err
+	}
+	dst := filepath.Join(
+		quarantinePath,
+		fmt.Sprintf("%d-%s", time.Now().Unix(), filepath.Base(fileName)),
+	)
+	return dst, os.Rename(fileName, dst)
+}
@@ -0,0 +1,44 @@
+package stream
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyToolFailures(t *testing.T) {
+	dir, err := ioutil.TempDir("", "verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "video.mp4")
+	if err := ioutil.WriteFile(file, []byte("video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	failing := filepath.Join(dir, "ffprobe")
+	if err := ioutil.WriteFile(failing, []byte("#!/bin/sh\necho 'Invalid data' >&2\nexit 1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	notExecutable := filepath.Join(dir, "not-executable")
+	if err := ioutil.WriteFile(notExecutable, []byte("#!/bin/sh\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{filepath.Join(dir, "missing"), "missing-ffprobe", notExecutable} {
+		err := (&Verifier{FFProbePath: path}).Verify(file)
+		if err == nil {
+			t.Fatalf("expected error for %s", path)
+		}
+		if _, ok := err.(*IntegrityError); ok {
+			t.Fatalf("failure to run %s must not be integrity error", path)
+		}
+	}
+	if _, ok := (&Verifier{FFProbePath: failing}).Verify(file).(*IntegrityError); !ok {
+		t.Fatal("failed probe of file must be integrity error")
+	}
+	if _, ok := (&Verifier{FFMpegPath: failing}).Verify(file).(*IntegrityError); !ok {
+		t.Fatal("failed decode of file must be integrity error")
+	}
+}
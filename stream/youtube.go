@@ -2,13 +2,10 @@ package stream
 
 import (
 	"fmt"
-	"io"
-	"net/http"
-	"os"
+	"net/url"
+	"strings"
 
 	"github.com/otium/ytdl"
-
-	"github.com/mxk/go-flowrate/flowrate"
 )
 
 // GetStreamURL returns Title of youtube video and url for re-stream
@@ -38,43 +35,78 @@ func getBestFormat(formats ytdl.FormatList) ytdl.Format {
 	return foundFormat
 }
 
-func Download(youtubeURL, fileName string, downloadLimit int) error {
-	dst := fmt.Sprintf("%s.download", fileName)
-	file, err := os.Create(dst)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
+// Download downloads video into fileName. The file is promoted to its final
+// name only if its size matches Content-Length and it passes verification
+func Download(youtubeURL, fileName string, downloadLimit int, v *Verifier) error {
 	info, err := ytdl.GetVideoInfo(youtubeURL)
 	if err != nil {
-		os.Remove(dst)
 		return err
 	}
 	foundFormat := getBestFormat(info.Formats)
 
 	u, err := info.GetDownloadURL(foundFormat)
 	if err != nil {
-		os.Remove(dst)
 		return err
 	}
-	resp, err := http.Get(u.String())
+	return downloadURL(u.String(), nil, fileName, downloadLimit, v)
+}
+
+// YoutubeSource handles youtube links. Resolver is used to get streamable
+// url, built-in ytdl decipher is used if it's nil
+type YoutubeSource struct {
+	Resolver Resolver
+}
+
+// Name returns name of provider
+func (s *YoutubeSource) Name() string {
+	return "youtube"
+}
+
+// Match returns true for youtube hosts
+func (s *YoutubeSource) Match(u *url.URL) bool {
+	switch strings.TrimPrefix(strings.ToLower(u.Host), "www.") {
+	case "youtube.com", "m.youtube.com", "youtu.be":
+		return true
+	}
+	return false
+}
+
+// Resolve returns media with streamable url
+func (s *YoutubeSource) Resolve(link string) (*Media, error) {
+	return s.resolver().Resolve(link)
+}
+
+// Probe returns title and duration of video
+func (s *YoutubeSource) Probe(link string) (*Media, error) {
+	return s.resolver().Resolve(link)
+}
+
+// Download saves video into fileName
+func (s *YoutubeSource) Download(link, fileName string, downloadLimit int, v *Verifier) error {
+	media, err := s.Resolve(link)
 	if err != nil {
-		os.Remove(dst)
 		return err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		return fmt.Errorf("Invalid status code: %d", resp.StatusCode)
+	if media.IsManifest {
+		return ErrNotDownloadable
 	}
-	wrappedIn := flowrate.NewReader(resp.Body, int64(downloadLimit)*1024)
-	_, err = io.Copy(file, wrappedIn)
+	return downloadURL(media.URL, media.Headers, fileName, downloadLimit, v)
+}
+
+// Stream re-streams video to dst
+func (s *YoutubeSource) Stream(ffmpeg, link, dst string) error {
+	media, err := s.Resolve(link)
 	if err != nil {
-		os.Remove(dst)
-		return err
+		return fmt.Errorf("Got error %s while getting streamable  youtube url for video %s ", err, link)
 	}
-	RenameFile(fileName)
+	return FromURLAt(ffmpeg, media.URL, dst, Options{Headers: media.Headers})
+}
 
-	return nil
+func (s *YoutubeSource) resolver() Resolver {
+	if s.Resolver == nil {
+		return &YtdlResolver{}
+	}
+	return s.Resolver
 }
 
 func contains(resolution string, resolutions []string) bool {